@@ -0,0 +1,108 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Clone returns a deep copy of the program that shares no expressions with
+// it.
+func (p *Program) Clone() *Program {
+	return &Program{
+		Expressions: CloneExpressions(p.Expressions),
+		Input:       bytes.Clone(p.Input),
+	}
+}
+
+func CloneExpressions(exprs []Expression) []Expression {
+	if exprs == nil {
+		return nil
+	}
+	cloned := make([]Expression, len(exprs))
+	for idx, expr := range exprs {
+		cloned[idx] = Clone(expr)
+	}
+	return cloned
+}
+
+// Clone returns a deep copy of the expression.
+func Clone(expr Expression) Expression {
+	switch e := expr.(type) {
+	case *PointerIncrementExpression:
+		c := *e
+		return &c
+	case *PointerDecrementExpression:
+		c := *e
+		return &c
+	case *ValueIncrementExpression:
+		c := *e
+		return &c
+	case *ValueDecrementExpression:
+		c := *e
+		return &c
+	case *OutputExpression:
+		c := *e
+		return &c
+	case *InputExpression:
+		c := *e
+		return &c
+	case *DebugExpression:
+		c := *e
+		return &c
+	case *CallExpression:
+		c := *e
+		return &c
+	case *ForkExpression:
+		c := *e
+		return &c
+	case *ValueResetExpression:
+		c := *e
+		if e.Loop != nil {
+			c.Loop = Clone(e.Loop).(*WhileExpression)
+		}
+		return &c
+	case *ZeroSearchExpression:
+		c := *e
+		if e.Loop != nil {
+			c.Loop = Clone(e.Loop).(*WhileExpression)
+		}
+		return &c
+	case *Comment:
+		c := *e
+		c.Body = bytes.Clone(e.Body)
+		return &c
+	case *WhileExpression:
+		c := *e
+		c.Body = CloneExpressions(e.Body)
+		return &c
+	case *ProcedureExpression:
+		c := *e
+		c.Body = CloneExpressions(e.Body)
+		return &c
+	case *PointerMoveExpression:
+		c := *e
+		c.Expressions = CloneExpressions(e.Expressions)
+		return &c
+	case *ValueChangeExpression:
+		c := *e
+		c.Expressions = CloneExpressions(e.Expressions)
+		return &c
+	case *MultiplePointerIncrementExpression:
+		c := *e
+		c.Expressions = CloneExpressions(e.Expressions)
+		return &c
+	case *MultiplePointerDecrementExpression:
+		c := *e
+		c.Expressions = CloneExpressions(e.Expressions)
+		return &c
+	case *MultipleValueIncrementExpression:
+		c := *e
+		c.Expressions = CloneExpressions(e.Expressions)
+		return &c
+	case *MultipleValueDecrementExpression:
+		c := *e
+		c.Expressions = CloneExpressions(e.Expressions)
+		return &c
+	}
+	panic(fmt.Sprintf("ast: can not clone %T", expr))
+}
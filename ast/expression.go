@@ -127,6 +127,26 @@ func (e *InputExpression) String() string {
 	return string(e.Bytes())
 }
 
+type DebugExpression struct {
+	Pos int
+}
+
+func (e *DebugExpression) StartPos() int {
+	return e.Pos
+}
+
+func (e *DebugExpression) EndPos() int {
+	return e.Pos
+}
+
+func (e *DebugExpression) Bytes() []byte {
+	return []byte{'#'}
+}
+
+func (e *DebugExpression) String() string {
+	return string(e.Bytes())
+}
+
 type WhileExpression struct {
 	StartPosition int
 	EndPosition   int
@@ -154,6 +174,73 @@ func (e *WhileExpression) String() string {
 	return string(e.Bytes())
 }
 
+type ProcedureExpression struct {
+	StartPosition int
+	EndPosition   int
+	Body          []Expression
+}
+
+func (e *ProcedureExpression) StartPos() int {
+	return e.StartPosition
+}
+
+func (e *ProcedureExpression) EndPos() int {
+	return e.EndPosition
+}
+
+func (e *ProcedureExpression) Bytes() []byte {
+	b := []byte{'('}
+	for _, expr := range e.Body {
+		b = append(b, expr.Bytes()...)
+	}
+	b = append(b, ')')
+	return b
+}
+
+func (e *ProcedureExpression) String() string {
+	return string(e.Bytes())
+}
+
+type CallExpression struct {
+	Pos int
+}
+
+func (e *CallExpression) StartPos() int {
+	return e.Pos
+}
+
+func (e *CallExpression) EndPos() int {
+	return e.Pos
+}
+
+func (e *CallExpression) Bytes() []byte {
+	return []byte{':'}
+}
+
+func (e *CallExpression) String() string {
+	return string(e.Bytes())
+}
+
+type ForkExpression struct {
+	Pos int
+}
+
+func (e *ForkExpression) StartPos() int {
+	return e.Pos
+}
+
+func (e *ForkExpression) EndPos() int {
+	return e.Pos
+}
+
+func (e *ForkExpression) Bytes() []byte {
+	return []byte{'Y'}
+}
+
+func (e *ForkExpression) String() string {
+	return string(e.Bytes())
+}
+
 type Comment struct {
 	Start int
 	End   int
@@ -150,8 +150,12 @@ func (e *ValueChangeExpression) String() string {
 	return string(e.Bytes())
 }
 
+// ValueResetExpression is a loop clearing the cell, like "[-]" or "[+++]".
+// Any odd change reaches zero.
 type ValueResetExpression struct {
 	Pos int
+	// Loop is the loop the reset replaces, nil for "[-]".
+	Loop *WhileExpression
 }
 
 func (e *ValueResetExpression) StartPos() int {
@@ -159,10 +163,61 @@ func (e *ValueResetExpression) StartPos() int {
 }
 
 func (e *ValueResetExpression) EndPos() int {
-	return e.Pos + 3
+	if e.Loop != nil {
+		return e.Loop.EndPos()
+	}
+	return e.Pos + 2
+}
+
+// Step returns the change of the cell on every iteration.
+func (e *ValueResetExpression) Step() int {
+	if e.Loop == nil {
+		return -1
+	}
+	step := 0
+	for _, expr := range e.Loop.Body {
+		switch b := expr.(type) {
+		case *ValueIncrementExpression:
+			step++
+		case *ValueDecrementExpression:
+			step--
+		case *ValueChangeExpression:
+			step += b.Count
+		case *MultipleValueIncrementExpression:
+			step += b.Count
+		case *MultipleValueDecrementExpression:
+			step -= b.Count
+		}
+	}
+	return step
+}
+
+// Size returns the number of instructions in the body of the loop.
+func (e *ValueResetExpression) Size() int {
+	if e.Loop == nil {
+		return 1
+	}
+	size := 0
+	for _, expr := range e.Loop.Body {
+		switch b := expr.(type) {
+		case *Comment:
+		case *ValueChangeExpression:
+			size += len(b.Expressions)
+		case *MultipleValueIncrementExpression:
+			size += len(b.Expressions)
+		case *MultipleValueDecrementExpression:
+			size += len(b.Expressions)
+		default:
+			size++
+		}
+	}
+	return size
 }
 
 func (e *ValueResetExpression) Bytes() []byte {
+	if e.Loop != nil {
+		return e.Loop.Bytes()
+	}
 	return []byte{'[', '-', ']'}
 }
 
@@ -170,33 +225,89 @@ func (e *ValueResetExpression) String() string {
 	return string(e.Bytes())
 }
 
+// ZeroSearchExpression is a loop only moving the pointer, like "[>]" or
+// "[<<]", which stops on the first zero cell in steps of SearchWindow.
 type ZeroSearchExpression struct {
 	StartPosition int
 	EndPosition   int
 
 	SearchWindow int
+	// Loop is the loop the search replaces, nil when its body is just the
+	// moves of the window.
+	Loop *WhileExpression
 }
 
 func (e *ZeroSearchExpression) StartPos() int {
-	return e.StartPos()
+	return e.StartPosition
 }
 
 func (e *ZeroSearchExpression) EndPos() int {
-	return e.EndPos()
+	return e.EndPosition
+}
+
+// Size returns the number of instructions in the body of the loop.
+func (e *ZeroSearchExpression) Size() int {
+	if e.Loop == nil {
+		return max(e.SearchWindow, -e.SearchWindow)
+	}
+	size := 0
+	for _, expr := range e.Loop.Body {
+		switch b := expr.(type) {
+		case *Comment:
+		case *PointerMoveExpression:
+			size += len(b.Expressions)
+		case *MultiplePointerIncrementExpression:
+			size += len(b.Expressions)
+		case *MultiplePointerDecrementExpression:
+			size += len(b.Expressions)
+		default:
+			size++
+		}
+	}
+	return size
+}
+
+// Reach returns how far left and right of where an iteration starts the
+// body moves the pointer, which may be further than SearchWindow as in
+// "[>><]".
+func (e *ZeroSearchExpression) Reach() (int, int) {
+	if e.Loop == nil {
+		return min(0, e.SearchWindow), max(0, e.SearchWindow)
+	}
+	left, right, offset := 0, 0, 0
+	var moves func(exprs []Expression)
+	moves = func(exprs []Expression) {
+		for _, expr := range exprs {
+			switch b := expr.(type) {
+			case *PointerIncrementExpression:
+				offset++
+			case *PointerDecrementExpression:
+				offset--
+			case *PointerMoveExpression:
+				moves(b.Expressions)
+			case *MultiplePointerIncrementExpression:
+				moves(b.Expressions)
+			case *MultiplePointerDecrementExpression:
+				moves(b.Expressions)
+			}
+			left, right = min(left, offset), max(right, offset)
+		}
+	}
+	moves(e.Loop.Body)
+	return left, right
 }
 
 func (e *ZeroSearchExpression) Bytes() []byte {
+	if e.Loop != nil {
+		return e.Loop.Bytes()
+	}
 	b := []byte{'['}
 	if e.SearchWindow != 0 {
 		symbol := byte('>')
 		if e.SearchWindow < 0 {
 			symbol = '<'
 		}
-		count := e.SearchWindow
-		if count < 0 {
-			count = -count
-		}
-		for i := 0; i < count; i++ {
+		for i := 0; i < max(e.SearchWindow, -e.SearchWindow); i++ {
 			b = append(b, symbol)
 		}
 	}
@@ -2,6 +2,9 @@ package ast
 
 type Program struct {
 	Expressions []Expression
+	// Input is the data following an input separator, nil if the source
+	// has none.
+	Input []byte
 }
 
 func (p *Program) String() string {
@@ -0,0 +1,50 @@
+package ast
+
+import (
+	"sort"
+)
+
+type SourceMap struct {
+	lines []int
+	size  int
+}
+
+func NewSourceMap(src []byte) *SourceMap {
+	lines := []int{0}
+	for pos, b := range src {
+		if b == '\n' {
+			lines = append(lines, pos+1)
+		}
+	}
+	return &SourceMap{
+		lines: lines,
+		size:  len(src),
+	}
+}
+
+// Position returns the 1-based line and column of pos.
+func (m *SourceMap) Position(pos int) (int, int) {
+	line := sort.Search(len(m.lines), func(i int) bool {
+		return m.lines[i] > pos
+	})
+	if line == 0 {
+		return 1, pos + 1
+	}
+	return line, pos - m.lines[line-1] + 1
+}
+
+// LineRange returns the [start, end) positions of the 1-based line.
+func (m *SourceMap) LineRange(line int) (int, int, bool) {
+	if line < 1 || line > len(m.lines) {
+		return 0, 0, false
+	}
+	end := m.size
+	if line < len(m.lines) {
+		end = m.lines[line]
+	}
+	return m.lines[line-1], end, true
+}
+
+func (m *SourceMap) Lines() int {
+	return len(m.lines)
+}
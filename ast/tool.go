@@ -22,9 +22,14 @@ func printAST(expr Expression, indent string, isLast bool) {
 	}
 	fmt.Printf("%s: %s\n", nodeType, expr.String())
 
-	if whileExpr, ok := expr.(*WhileExpression); ok {
-		for i, child := range whileExpr.Body {
-			printAST(child, indent, i == len(whileExpr.Body)-1)
+	switch e := expr.(type) {
+	case *WhileExpression:
+		for i, child := range e.Body {
+			printAST(child, indent, i == len(e.Body)-1)
+		}
+	case *ProcedureExpression:
+		for i, child := range e.Body {
+			printAST(child, indent, i == len(e.Body)-1)
 		}
 	}
 }
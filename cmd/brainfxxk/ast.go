@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+
+	"github.com/rosylilly/brainfxxk/ast"
+	"github.com/rosylilly/brainfxxk/optimizer"
+)
+
+func astCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("ast")
+	sf := addSyntaxFlags(fs)
+	of := addOptimizerFlags(fs)
+	optimize := fs.Bool("optimize", true, "print the optimized tree")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return usageError(fs, "ast takes one file")
+	}
+
+	syntax, err := sf.load()
+	if err != nil {
+		return err
+	}
+	_, src, err := readSource(fs)
+	if err != nil {
+		return err
+	}
+	p, err := parseSource(src, syntax)
+	if err != nil {
+		return err
+	}
+	if *optimize {
+		if p, err = optimizer.NewOptimizerWithConfig(of.load()).Optimize(p); err != nil {
+			return err
+		}
+	}
+
+	ast.PrintASTList(p.Expressions)
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+
+	"github.com/rosylilly/brainfxxk/interpreter"
+	"github.com/rosylilly/brainfxxk/optimizer"
+)
+
+// timing summarizes the durations of one stage over every run.
+type timing struct {
+	Mean   time.Duration `json:"mean_ns"`
+	Stddev time.Duration `json:"stddev_ns"`
+	Min    time.Duration `json:"min_ns"`
+	Max    time.Duration `json:"max_ns"`
+}
+
+func newTiming(samples []time.Duration) timing {
+	t := timing{Min: samples[0], Max: samples[0]}
+	var total float64
+	for _, s := range samples {
+		total += float64(s)
+		t.Min = min(t.Min, s)
+		t.Max = max(t.Max, s)
+	}
+	mean := total / float64(len(samples))
+
+	var variance float64
+	for _, s := range samples {
+		variance += (float64(s) - mean) * (float64(s) - mean)
+	}
+	t.Mean = time.Duration(mean)
+	t.Stddev = time.Duration(math.Sqrt(variance / float64(len(samples))))
+	return t
+}
+
+type benchResult struct {
+	Runs     int    `json:"runs"`
+	Parse    timing `json:"parse"`
+	Optimize timing `json:"optimize"`
+	Run      timing `json:"run"`
+	Steps    int    `json:"steps"`
+	// StepsPerSecond is measured against the mean run time.
+	StepsPerSecond float64 `json:"steps_per_second"`
+}
+
+func (r *benchResult) write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		return json.NewEncoder(w).Encode(r)
+	case "text":
+		_, err := fmt.Fprintf(w, "runs:     %d\nparse:    %v\noptimize: %v\nrun:      %v\nsteps:    %d\nsteps/s:  %.0f\n",
+			r.Runs, r.Parse, r.Optimize, r.Run, r.Steps, r.StepsPerSecond)
+		return err
+	}
+	return fmt.Errorf("unknown bench format: %s", format)
+}
+
+func (t timing) String() string {
+	return fmt.Sprintf("%v ± %v (min %v, max %v)", t.Mean, t.Stddev, t.Min, t.Max)
+}
+
+func benchCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("bench")
+	rf := addRuntimeFlags(fs)
+	runs := fs.Int("n", 10, "number of runs")
+	inputFile := fs.String("input", "", "file to read program input from on every run")
+	format := fs.String("format", "text", "result format: text or json")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return usageError(fs, "bench takes one file")
+	}
+	if *runs < 1 {
+		return usageError(fs, "-n must be positive")
+	}
+	if *format != "text" && *format != "json" {
+		return usageError(fs, "unknown bench format: %s", *format)
+	}
+
+	c, err := rf.load()
+	if err != nil {
+		return err
+	}
+	_, src, err := readSource(fs)
+	if err != nil {
+		return err
+	}
+	var input []byte
+	if *inputFile != "" {
+		if input, err = os.ReadFile(*inputFile); err != nil {
+			return err
+		}
+	}
+
+	parse := make([]time.Duration, 0, *runs)
+	optimize := make([]time.Duration, 0, *runs)
+	run := make([]time.Duration, 0, *runs)
+	steps := 0
+	for n := 0; n < *runs; n++ {
+		before := time.Now()
+		p, err := parseSource(src, &c.Syntax)
+		if err != nil {
+			return err
+		}
+		parse = append(parse, time.Since(before))
+
+		before = time.Now()
+		if p, err = optimizer.NewOptimizerWithConfig(c.Optimizer).Optimize(p); err != nil {
+			return err
+		}
+		optimize = append(optimize, time.Since(before))
+
+		// the program is already optimized, so the interpreter runs it as
+		// it is.
+		rc := *c
+		rc.Unoptimized = true
+		rc.Writer = io.Discard
+		rc.Reader = bytes.NewReader(input)
+		ip := interpreter.NewInterpreter(p, &rc)
+
+		before = time.Now()
+		if _, err := ip.Run(ctx); err != nil {
+			return err
+		}
+		run = append(run, time.Since(before))
+		steps = ip.Steps()
+	}
+
+	r := &benchResult{
+		Runs:     *runs,
+		Parse:    newTiming(parse),
+		Optimize: newTiming(optimize),
+		Run:      newTiming(run),
+		Steps:    steps,
+	}
+	if r.Run.Mean > 0 {
+		r.StepsPerSecond = float64(steps) / r.Run.Mean.Seconds()
+	}
+	return r.write(os.Stdout, *format)
+}
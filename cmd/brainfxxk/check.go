@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rosylilly/brainfxxk/parser"
+)
+
+func checkCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("check")
+	sf := addSyntaxFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	syntax, err := sf.load()
+	if err != nil {
+		return err
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	code := exitOK
+	for _, file := range files {
+		var src []byte
+		if file == "-" {
+			src, err = io.ReadAll(os.Stdin)
+		} else {
+			src, err = os.ReadFile(file)
+		}
+		if err == nil {
+			_, err = parseSource(src, syntax)
+		}
+		if err == nil {
+			continue
+		}
+
+		fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+		if errors.Is(err, parser.ErrInvalidSyntax) && code != exitRuntimeError {
+			code = exitSyntaxError
+		} else {
+			code = exitRuntimeError
+		}
+	}
+
+	if code != exitOK {
+		return &exitError{code: code}
+	}
+	return nil
+}
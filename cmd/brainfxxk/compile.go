@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/rosylilly/brainfxxk/compiler"
+	"github.com/rosylilly/brainfxxk/optimizer"
+)
+
+func compileCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("compile")
+	sf := addSyntaxFlags(fs)
+	of := addOptimizerFlags(fs)
+	memorySize := fs.Int("memory-size", defaultMemorySize, "memory size")
+	output := fs.String("o", "", "write the C source to this file instead of stdout")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return usageError(fs, "compile takes one file")
+	}
+
+	syntax, err := sf.load()
+	if err != nil {
+		return err
+	}
+	_, src, err := readSource(fs)
+	if err != nil {
+		return err
+	}
+	p, err := parseSource(src, syntax)
+	if err != nil {
+		return err
+	}
+	if p, err = optimizer.NewOptimizerWithConfig(of.load()).Optimize(p); err != nil {
+		return err
+	}
+
+	c := &compiler.Config{MemorySize: *memorySize}
+	if *output == "" {
+		return compiler.CompileC(os.Stdout, p, c)
+	}
+	fp, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := compiler.CompileC(fp, p, c); err != nil {
+		fp.Close()
+		return err
+	}
+	return fp.Close()
+}
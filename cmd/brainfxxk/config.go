@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rosylilly/brainfxxk/ast"
+	"github.com/rosylilly/brainfxxk/interpreter"
+	"github.com/rosylilly/brainfxxk/lexer"
+	"github.com/rosylilly/brainfxxk/optimizer"
+	"github.com/rosylilly/brainfxxk/parser"
+)
+
+const (
+	defaultMemorySize       = 30000
+	defaultOutputBufferSize = 4096
+)
+
+// syntaxFlags are shared by the commands reading source code.
+type syntaxFlags struct {
+	dialect string
+	config  lexer.Config
+}
+
+func addSyntaxFlags(fs *flag.FlagSet) *syntaxFlags {
+	f := &syntaxFlags{dialect: lexer.Standard.Name}
+	fs.StringVar(&f.dialect, "dialect", f.dialect, "dialect name or JSON dialect file")
+	fs.BoolVar(&f.config.InputSeparator, "input-separator", f.config.InputSeparator, "read program input from after '!' in the source")
+	fs.BoolVar(&f.config.Procedures, "pbrain", f.config.Procedures, "enable pbrain procedures")
+	fs.BoolVar(&f.config.Fork, "brainfork", f.config.Fork, "enable Brainfork threads")
+	fs.BoolVar(&f.config.DebugDump, "debug-dump", f.config.DebugDump, "dump the tape to stderr on '#'")
+	return f
+}
+
+func (f *syntaxFlags) load() (*lexer.Config, error) {
+	d, err := loadDialect(f.dialect)
+	if err != nil {
+		return nil, err
+	}
+	c := f.config
+	c.Dialect = d
+	return &c, nil
+}
+
+// optimizerFlags choose the optimization passes.
+type optimizerFlags struct {
+	config optimizer.Config
+}
+
+func addOptimizerFlags(fs *flag.FlagSet) *optimizerFlags {
+	f := &optimizerFlags{config: *optimizer.DefaultConfig()}
+	for level := 0; level <= optimizer.MaxLevel; level++ {
+		passes, _ := (&optimizer.Config{Level: level}).Passes()
+		usage := "run the program as parsed"
+		if len(passes) > 0 {
+			usage = "optimize with " + passNames(passes)
+		}
+		if level == optimizer.DefaultLevel {
+			usage += " (default)"
+		}
+		fs.BoolFunc(fmt.Sprintf("O%d", level), usage, func(string) error {
+			f.config.Level = level
+			return nil
+		})
+	}
+
+	fs.Func("disable-pass", "skip optimization passes, comma separated or repeated: "+passNames(optimizer.Passes), func(s string) error {
+		for _, name := range strings.Split(s, ",") {
+			if _, err := optimizer.LookupPass(name); err != nil {
+				return err
+			}
+			f.config.DisabledPasses = append(f.config.DisabledPasses, name)
+		}
+		return nil
+	})
+	return f
+}
+
+func passNames(passes []*optimizer.Pass) string {
+	names := make([]string, 0, len(passes))
+	for _, p := range passes {
+		names = append(names, p.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func (f *optimizerFlags) load() *optimizer.Config {
+	c := f.config
+	return &c
+}
+
+// runtimeFlags are shared by the commands running programs.
+type runtimeFlags struct {
+	*syntaxFlags
+	*optimizerFlags
+	config         interpreter.Config
+	forkCopyMemory bool
+	flush          string
+}
+
+func addRuntimeFlags(fs *flag.FlagSet) *runtimeFlags {
+	f := &runtimeFlags{
+		syntaxFlags:    addSyntaxFlags(fs),
+		optimizerFlags: addOptimizerFlags(fs),
+		config:         interpreter.Config{MemorySize: defaultMemorySize, OutputBufferSize: defaultOutputBufferSize},
+	}
+	fs.IntVar(&f.config.MemorySize, "memory-size", f.config.MemorySize, "memory size")
+	fs.BoolVar(&f.config.RaiseErrorOnOverflow, "raise-error-on-overflow", f.config.RaiseErrorOnOverflow, "raise error on overflow")
+	fs.BoolVar(&f.config.RaiseErrorOnEOF, "raise-error-on-eof", f.config.RaiseErrorOnEOF, "raise error on eof")
+	fs.IntVar(&f.config.MaxSteps, "max-steps", f.config.MaxSteps, "stop after executing this many instructions, 0 for no limit")
+	fs.IntVar(&f.config.MaxOutputBytes, "max-output-bytes", f.config.MaxOutputBytes, "stop after writing this many bytes, 0 for no limit")
+	fs.IntVar(&f.config.MaxInputBytes, "max-input-bytes", f.config.MaxInputBytes, "stop after reading this many bytes, 0 for no limit")
+	fs.IntVar(&f.config.MaxMemorySize, "max-memory-size", f.config.MaxMemorySize, "let the tape grow up to this many cells")
+	fs.IntVar(&f.config.MaxCallDepth, "max-call-depth", f.config.MaxCallDepth, "max depth of pbrain procedure calls")
+	fs.IntVar(&f.config.OutputBufferSize, "output-buffer-size", f.config.OutputBufferSize, "buffer this many bytes of output, 0 to write every byte")
+	fs.StringVar(&f.flush, "flush", f.flush, "also flush output on: newline, input or exit, comma separated; input, plus newline on a terminal, by default")
+	fs.BoolVar(&f.forkCopyMemory, "fork-copy-memory", f.forkCopyMemory, "give each Brainfork thread a copy of the tape")
+	fs.BoolVar(&f.config.SerializeForkOutput, "fork-serialize-output", f.config.SerializeForkOutput, "write Brainfork thread output in a deterministic order")
+	return f
+}
+
+// load returns a config writing to stdout and reading from stdin.
+func (f *runtimeFlags) load() (*interpreter.Config, error) {
+	syntax, err := f.syntaxFlags.load()
+	if err != nil {
+		return nil, err
+	}
+
+	c := f.config
+	c.Syntax = *syntax
+	c.Optimizer = f.optimizerFlags.load()
+	c.Writer = os.Stdout
+	c.Reader = os.Stdin
+	if f.flush == "" {
+		c.FlushPolicy = interpreter.FlushOnInput
+		if isTerminal(os.Stdout) {
+			c.FlushPolicy |= interpreter.FlushOnNewline
+		}
+	} else if c.FlushPolicy, err = interpreter.ParseFlushPolicy(f.flush); err != nil {
+		return nil, err
+	}
+	if f.forkCopyMemory {
+		c.ForkMemory = interpreter.CopiedForkMemory
+	}
+	if c.Syntax.DebugDump {
+		c.DebugWriter = os.Stderr
+	}
+	return &c, nil
+}
+
+// ioFlags let run take its source inline and its input and output from
+// somewhere other than stdin and stdout.
+type ioFlags struct {
+	expr        string
+	inputFile   string
+	inputString string
+	outputFile  string
+}
+
+func addIOFlags(fs *flag.FlagSet) *ioFlags {
+	f := &ioFlags{}
+	fs.StringVar(&f.expr, "e", f.expr, "run this code instead of a file")
+	fs.StringVar(&f.inputFile, "input", f.inputFile, "file to read program input from instead of stdin")
+	fs.StringVar(&f.inputString, "input-string", f.inputString, "string to use as program input instead of stdin")
+	fs.StringVar(&f.outputFile, "output", f.outputFile, "file to write program output to instead of stdout")
+	return f
+}
+
+func (f *ioFlags) validate(fs *flag.FlagSet) error {
+	if f.expr != "" && fs.NArg() > 0 {
+		return usageError(fs, "-e can not be used with a file")
+	}
+	if f.inputFile != "" && f.inputString != "" {
+		return usageError(fs, "-input and -input-string can not be used together")
+	}
+	return nil
+}
+
+// hasInput reports whether program input was given by a flag.
+func (f *ioFlags) hasInput() bool {
+	return f.inputFile != "" || f.inputString != ""
+}
+
+// readSource returns the code given by -e, or reads it like readSource.
+func (f *ioFlags) readSource(fs *flag.FlagSet) (string, []byte, error) {
+	if f.expr != "" {
+		return "-e", []byte(f.expr), nil
+	}
+	return readSource(fs)
+}
+
+// open points c at the input and output given by the flags and returns a
+// function closing the files it opened.
+func (f *ioFlags) open(c *interpreter.Config) (func() error, error) {
+	var files []*os.File
+	closeFiles := func() error {
+		var err error
+		for _, fp := range files {
+			err = errors.Join(err, fp.Close())
+		}
+		files = nil
+		return err
+	}
+
+	switch {
+	case f.inputFile != "":
+		fp, err := os.Open(f.inputFile)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, fp)
+		c.Reader = fp
+	case f.inputString != "":
+		c.Reader = strings.NewReader(f.inputString)
+	}
+
+	if f.outputFile != "" {
+		fp, err := os.Create(f.outputFile)
+		if err != nil {
+			closeFiles()
+			return nil, err
+		}
+		files = append(files, fp)
+		c.Writer = fp
+	}
+	return closeFiles, nil
+}
+
+// readSource reads the file named by the first argument, or stdin without
+// one.
+func readSource(fs *flag.FlagSet) (string, []byte, error) {
+	if fs.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		return "stdin", src, err
+	}
+	src, err := os.ReadFile(fs.Arg(0))
+	return fs.Arg(0), src, err
+}
+
+func parseSource(src []byte, c *lexer.Config) (*ast.Program, error) {
+	return parser.ParseWithConfig(bytes.NewReader(src), c)
+}
+
+func loadDialect(name string) (*lexer.Dialect, error) {
+	d, err := lexer.LookupDialect(name)
+	if !errors.Is(err, lexer.ErrUnknownDialect) {
+		return d, err
+	}
+
+	fp, openErr := os.Open(name)
+	if openErr != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	return lexer.LoadDialect(fp)
+}
+
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice != 0
+}
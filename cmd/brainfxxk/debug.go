@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rosylilly/brainfxxk/ast"
+	"github.com/rosylilly/brainfxxk/interpreter"
+)
+
+const debugHelp = `commands:
+  s, step            execute the next instruction
+  n, next            execute the next instruction, running loops to completion
+  c, continue        run until a breakpoint, a watched cell changes or the end
+  sb, back           undo the last instruction
+  rw, rwrite CELL    run backwards to the last instruction writing CELL
+  goto STEP          move backwards or forwards to the numbered step
+  b, break POS       break at source position POS
+  bl, breakline LINE break at the first instruction on LINE
+  clear              remove all breakpoints
+  w, watch CELL      stop when CELL changes
+  unwatch CELL       stop watching CELL
+  t, tape [RADIUS]   print the cells around the pointer
+  where              print the next instruction
+  q, quit            exit the debugger
+`
+
+func debugCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("debug")
+	rf := addRuntimeFlags(fs)
+	inputFile := fs.String("input", "", "file to read program input from")
+	checkpointInterval := fs.Int("checkpoint-interval", 4096, "instructions between checkpoints of the history")
+	maxCheckpoints := fs.Int("max-checkpoints", 256, "checkpoints kept, which bounds how far back the history reaches")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageError(fs, "debug takes one file")
+	}
+
+	c, err := rf.load()
+	if err != nil {
+		return err
+	}
+	// output shows up between the debugger's prompts.
+	c.OutputBufferSize = 0
+	_, src, err := readSource(fs)
+	if err != nil {
+		return err
+	}
+
+	// stdin holds the debugger commands.
+	c.Reader = &bytes.Buffer{}
+	if *inputFile != "" {
+		fp, err := os.Open(*inputFile)
+		if err != nil {
+			return err
+		}
+		defer fp.Close()
+		c.Reader = fp
+	}
+	c.History = interpreter.NewHistory()
+	c.History.CheckpointInterval = *checkpointInterval
+	c.History.MaxCheckpoints = *maxCheckpoints
+
+	p, err := parseSource(src, &c.Syntax)
+	if err != nil {
+		return err
+	}
+
+	ip := interpreter.NewInterpreter(p, c)
+	ip.Source = ast.NewSourceMap(src)
+
+	return debugLoop(ctx, ip, os.Stdin, os.Stdout)
+}
+
+func debugLoop(ctx context.Context, ip *interpreter.Interpreter, in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	printWhere(ip, out)
+
+	for {
+		fmt.Fprint(out, "(bfdb) ")
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		var stop *interpreter.Stop
+		var err error
+		switch fields[0] {
+		case "s", "step":
+			stop, err = ip.Step(ctx)
+		case "n", "next":
+			stop, err = ip.StepOver(ctx)
+		case "c", "continue":
+			stop, err = ip.Continue(ctx)
+		case "sb", "back":
+			stop, err = ip.StepBack(ctx)
+		case "rw", "rwrite":
+			err = withNumber(fields, func(cell int) error {
+				var err error
+				stop, err = ip.ReverseToWrite(ctx, cell)
+				return err
+			})
+		case "goto":
+			err = withNumber(fields, func(n int) error {
+				stop = &interpreter.Stop{Reason: interpreter.StopStep}
+				return ip.GoToStep(ctx, n)
+			})
+		case "b", "break":
+			err = withNumber(fields, ip.SetBreakpoint)
+		case "bl", "breakline":
+			err = withNumber(fields, ip.SetLineBreakpoint)
+		case "clear":
+			ip.ClearBreakpoints()
+		case "w", "watch":
+			err = withNumber(fields, ip.Watch)
+		case "unwatch":
+			err = withNumber(fields, func(cell int) error {
+				ip.Unwatch(cell)
+				return nil
+			})
+		case "t", "tape":
+			radius := 5
+			if len(fields) > 1 {
+				radius, err = strconv.Atoi(fields[1])
+			}
+			if err == nil {
+				err = ip.WriteTape(out, radius)
+			}
+		case "where":
+			printWhere(ip, out)
+		case "q", "quit":
+			return nil
+		case "h", "help":
+			fmt.Fprint(out, debugHelp)
+		default:
+			fmt.Fprintf(out, "unknown command: %s\n", fields[0])
+		}
+
+		if err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+			continue
+		}
+		if stop != nil {
+			switch stop.Reason {
+			case interpreter.StopWatch:
+				fmt.Fprintf(out, "\ncell %d changed: %d -> %d\n", stop.Cell, stop.Old, stop.New)
+			case interpreter.StopBreakpoint:
+				fmt.Fprintln(out, "\nbreakpoint")
+			}
+			printWhere(ip, out)
+		}
+	}
+}
+
+func withNumber(fields []string, f func(int) error) error {
+	if len(fields) != 2 {
+		return fmt.Errorf("%s requires one number", fields[0])
+	}
+	n, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return err
+	}
+	return f(n)
+}
+
+func printWhere(ip *interpreter.Interpreter, out io.Writer) {
+	expr, err := ip.Current()
+	if err != nil {
+		fmt.Fprintf(out, "error: %v\n", err)
+		return
+	}
+	if expr == nil {
+		fmt.Fprintf(out, "finished after %d instructions\n", ip.Count())
+		return
+	}
+
+	pos, _ := ip.CurrentPos()
+	code := expr.String()
+	switch e := expr.(type) {
+	case *ast.WhileExpression:
+		code = "["
+		if pos == e.EndPosition {
+			code = "]"
+		}
+	case *ast.ProcedureExpression:
+		code = "("
+		if pos == e.EndPosition {
+			code = ")"
+		}
+	}
+	// the pointer may leave the tape until a cell is used there.
+	cell := "?"
+	if ip.Pointer >= 0 && ip.Pointer < len(ip.Memory) {
+		cell = strconv.Itoa(int(ip.Memory[ip.Pointer]))
+	}
+	line, col := ip.Source.Position(pos)
+	fmt.Fprintf(out, "#%d %d:%d (pos %d) %s  [ptr %d = %s]\n", ip.Executed(), line, col, pos, code, ip.Pointer, cell)
+}
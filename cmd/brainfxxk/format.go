@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/rosylilly/brainfxxk/ast"
+	"github.com/rosylilly/brainfxxk/formatter"
+	"github.com/rosylilly/brainfxxk/lexer"
+)
+
+func fmtCommand(ctx context.Context, args []string) error {
+	return formatCommand("fmt", args, formatter.Format)
+}
+
+func minifyCommand(ctx context.Context, args []string) error {
+	return formatCommand("minify", args, formatter.Minify)
+}
+
+func formatCommand(name string, args []string, format func(io.Writer, *ast.Program) error) error {
+	fs := newFlagSet(name)
+	sf := addSyntaxFlags(fs)
+	write := fs.Bool("w", false, "write the result back to the file instead of stdout")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return usageError(fs, "%s takes one file", name)
+	}
+	if *write && fs.NArg() == 0 {
+		return usageError(fs, "-w requires a file")
+	}
+
+	syntax, err := sf.load()
+	if err != nil {
+		return err
+	}
+	// the result is spelled in standard symbols, which would replace the
+	// dialect of the file.
+	if *write && syntax.Dialect != lexer.Standard {
+		return usageError(fs, "-w can not be used with the %s dialect", syntax.Dialect.Name)
+	}
+	filename, src, err := readSource(fs)
+	if err != nil {
+		return err
+	}
+	p, err := parseSource(src, syntax)
+	if err != nil {
+		return err
+	}
+
+	if !*write {
+		return format(os.Stdout, p)
+	}
+	fp, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := format(fp, p); err != nil {
+		fp.Close()
+		return err
+	}
+	return fp.Close()
+}
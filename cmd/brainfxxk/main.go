@@ -2,64 +2,176 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"log"
 	"os"
-	"time"
+	"os/signal"
 
 	"github.com/rosylilly/brainfxxk/interpreter"
+	"github.com/rosylilly/brainfxxk/parser"
 )
 
-var (
-	config = &interpreter.Config{
-		Writer:               os.Stdout,
-		Reader:               os.Stdin,
-		MemorySize:           30000,
-		RaiseErrorOnOverflow: false,
-		RaiseErrorOnEOF:      false,
-		AstInfo:              false,
-	}
+const (
+	exitOK            = 0
+	exitRuntimeError  = 1
+	exitUsage         = 2
+	exitSyntaxError   = 3
+	exitLimitExceeded = 4
+	exitInterrupted   = 130
 )
 
+const programName = "brainfxxk"
+
+type command struct {
+	name  string
+	args  string
+	short string
+	run   func(ctx context.Context, args []string) error
+}
+
+var commands []*command
+
 func init() {
-	flag.Usage = func() {
-		fmt.Printf("Usage: %s [options...] [file]:\n", os.Args[0])
-		flag.PrintDefaults()
+	commands = []*command{
+		{name: "run", args: "[options...] [file]", short: "run a program", run: runCommand},
+		{name: "ast", args: "[options...] [file]", short: "print the syntax tree of a program", run: astCommand},
+		{name: "fmt", args: "[options...] [file]", short: "indent a program", run: fmtCommand},
+		{name: "minify", args: "[options...] [file]", short: "strip everything but instructions from a program", run: minifyCommand},
+		{name: "compile", args: "[options...] [file]", short: "translate a program into C", run: compileCommand},
+		{name: "debug", args: "[options...] file", short: "step through a program", run: debugCommand},
+		{name: "bench", args: "[options...] [file]", short: "measure how fast a program runs", run: benchCommand},
+		{name: "check", args: "[options...] [files...]", short: "report syntax errors", run: checkCommand},
+		{name: "test", args: "[options...] [paths...]", short: "compare the output of programs with their golden files", run: testCommand},
+		{name: "repl", args: "[options...]", short: "run code line by line on one tape", run: replCommand},
+		{name: "translate", args: "[options...] [file]", short: "rewrite a program into another dialect", run: translateCommand},
+		{name: "help", args: "[command]", short: "show help for a command", run: helpCommand},
 	}
-
-	flag.IntVar(&config.MemorySize, "memory-size", config.MemorySize, "memory size")
-	flag.BoolVar(&config.RaiseErrorOnOverflow, "raise-error-on-overflow", config.RaiseErrorOnOverflow, "raise error on overflow")
-	flag.BoolVar(&config.RaiseErrorOnEOF, "raise-error-on-eof", config.RaiseErrorOnEOF, "raise error on eof")
-	flag.BoolVar(&config.AstInfo, "ast-info", config.AstInfo, "show ast info")
 }
 
 func main() {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
 
-	flag.Parse()
+	os.Exit(execute(ctx, os.Args[1:]))
+}
+
+// execute runs the command named by the first argument. Without a known
+// command the arguments are passed to run, so "brainfxxk file.bf" keeps
+// working.
+func execute(ctx context.Context, args []string) int {
+	cmd := findCommand("run")
+	if len(args) > 0 {
+		if c := findCommand(args[0]); c != nil {
+			cmd = c
+			args = args[1:]
+		}
+	}
+
+	err := cmd.run(ctx, args)
+	code := exitCode(err)
+	var exit *exitError
+	if err != nil && !errors.As(err, &exit) {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", programName, err)
+	}
+	return code
+}
 
-	var source io.ReadCloser = os.Stdin
-	if flag.NArg() > 0 {
-		fp, err := os.Open(flag.Arg(0))
-		if err != nil {
-			log.Fatal(err)
+func findCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
 		}
+	}
+	return nil
+}
+
+// exitError ends the program with code after the command reported the
+// problem itself.
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+func exitCode(err error) int {
+	var exit *exitError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &exit):
+		return exit.code
+	case errors.Is(err, parser.ErrInvalidSyntax):
+		return exitSyntaxError
+	case errors.Is(err, interpreter.ErrStepLimitExceeded),
+		errors.Is(err, interpreter.ErrOutputLimitExceeded),
+		errors.Is(err, interpreter.ErrInputLimitExceeded),
+		errors.Is(err, interpreter.ErrMemoryLimitExceeded),
+		errors.Is(err, interpreter.ErrCallDepthExceeded):
+		return exitLimitExceeded
+	case errors.Is(err, context.Canceled):
+		return exitInterrupted
+	}
+	return exitRuntimeError
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	cmd := findCommand(name)
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s %s\n\n%s.\n\n", programName, cmd.name, cmd.args, capitalize(cmd.short))
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args into fs. Flag errors and help requests were already
+// printed by fs, so they come back as an exitError.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	err := fs.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return &exitError{code: exitOK}
+	}
+	if err != nil {
+		return &exitError{code: exitUsage}
+	}
+	return nil
+}
 
-		source = fp
+func usageError(fs *flag.FlagSet, format string, args ...any) error {
+	fmt.Fprintf(fs.Output(), format+"\n", args...)
+	fs.Usage()
+	return &exitError{code: exitUsage}
+}
+
+func helpCommand(ctx context.Context, args []string) error {
+	if len(args) > 0 {
+		cmd := findCommand(args[0])
+		if cmd == nil || cmd.name == "help" {
+			writeUsage(os.Stderr)
+			return &exitError{code: exitUsage}
+		}
+		return cmd.run(ctx, []string{"-h"})
 	}
-	defer source.Close()
 
-	before := time.Now()
-	defer func() {
-		fmt.Printf("\nelapsed: %v", time.Since(before))
-	}()
+	writeUsage(os.Stdout)
+	return nil
+}
+
+func writeUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s <command> [options...] [file]\n\ncommands:\n", programName)
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.short)
+	}
+	fmt.Fprintf(w, "\nWithout a command, %s runs the file. Exit status is %d for syntax errors,\n%d for exceeded limits and %d for other errors.\n", programName, exitSyntaxError, exitLimitExceeded, exitRuntimeError)
+}
 
-	if count, err := interpreter.Run(ctx, source, config); err != nil {
-		log.Fatal(err)
-	} else {
-		fmt.Println("Count: ", count)
+func capitalize(s string) string {
+	if s == "" {
+		return s
 	}
+	return string(s[0]-'a'+'A') + s[1:]
 }
@@ -0,0 +1,431 @@
+package main_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var binary string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "brainfxxk")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	binary = filepath.Join(dir, "brainfxxk")
+	build := exec.Command("go", "build", "-o", binary, ".")
+	build.Stderr = os.Stderr
+	if err := build.Run(); err != nil {
+		os.RemoveAll(dir)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+type cliResult struct {
+	stdout string
+	stderr string
+	code   int
+}
+
+func runCLI(t *testing.T, stdin string, args ...string) cliResult {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(binary, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	code := 0
+	if err := cmd.Run(); err != nil {
+		var exit *exec.ExitError
+		if !errors.As(err, &exit) {
+			t.Fatal(err)
+		}
+		code = exit.ExitCode()
+	}
+	return cliResult{stdout: stdout.String(), stderr: stderr.String(), code: code}
+}
+
+func TestCLIOutputFile(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "output.txt")
+
+	res := runCLI(t, "", "-input", "testdata/input.txt", "-output", output, "-e", ",[.,]")
+	if res.code != 0 || res.stdout != "" || res.stderr != "" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "abc\n" {
+		t.Errorf("expected %q, got %q", "abc\n", got)
+	}
+}
+
+func TestCLIOutputBuffer(t *testing.T) {
+	stdout, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stdout.Close()
+
+	// output is buffered by default, so the tape dumped by '#' reaches
+	// stderr while '.' is still unwritten, and ',' waits for input with it.
+	cmd := exec.Command(binary, "-flush", "exit", "-debug-dump", "-e", "+.#,")
+	cmd.Stdout = stdout
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	dump := bufio.NewReader(stderr)
+	if _, err := dump.ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected no output before exit, got %q", got)
+	}
+
+	stdin.Close()
+	if _, err := io.ReadAll(dump); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(stdout.Name()); string(got) != "\x01" {
+		t.Errorf("expected %q, got %q", "\x01", got)
+	}
+}
+
+func TestCLIDebug(t *testing.T) {
+	source := filepath.Join(t.TempDir(), "off-tape.bf")
+	if err := os.WriteFile(source, []byte("<>+."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// "<" leaves the tape for one step, "where" can't show a cell there.
+	res := runCLI(t, "step\nstep\n", "debug", "-O0", source)
+	if res.code != 0 || res.stderr != "" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if expected := "#1 1:2 (pos 1) >  [ptr -1 = ?]\n"; !strings.Contains(res.stdout, expected) {
+		t.Errorf("expected %q in %q", expected, res.stdout)
+	}
+}
+
+func TestCLIFormatWrite(t *testing.T) {
+	dir := t.TempDir()
+	standard := filepath.Join(dir, "standard.bf")
+	if err := os.WriteFile(standard, []byte("+[ - ]"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ook := filepath.Join(dir, "ook.bf")
+	if err := os.WriteFile(ook, []byte("Ook. Ook. Ook! Ook?"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	res := runCLI(t, "", "minify", "-w", standard)
+	if res.code != 0 || res.stdout != "" || res.stderr != "" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if got, _ := os.ReadFile(standard); string(got) != "+[-]" {
+		t.Errorf("expected %q, got %q", "+[-]", got)
+	}
+
+	// writing standard symbols back would lose the dialect of the file.
+	res = runCLI(t, "", "minify", "-w", "-dialect", "ook", ook)
+	if res.code != 2 || !strings.Contains(res.stderr, "-w can not be used with the ook dialect") {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if got, _ := os.ReadFile(ook); string(got) != "Ook. Ook. Ook! Ook?" {
+		t.Errorf("file was changed: %q", got)
+	}
+}
+
+func TestCLIBench(t *testing.T) {
+	res := runCLI(t, "", "bench", "-n", "3", "-format", "json", "../../example/hello-world.bf")
+	if res.code != 0 || res.stderr != "" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	var r struct {
+		Runs           int     `json:"runs"`
+		Steps          int     `json:"steps"`
+		StepsPerSecond float64 `json:"steps_per_second"`
+		Run            struct {
+			Mean int64 `json:"mean_ns"`
+			Min  int64 `json:"min_ns"`
+			Max  int64 `json:"max_ns"`
+		} `json:"run"`
+	}
+	if err := json.Unmarshal([]byte(res.stdout), &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Runs != 3 || r.Steps == 0 || r.StepsPerSecond <= 0 {
+		t.Errorf("unexpected result: %+v", r)
+	}
+	if r.Run.Min > r.Run.Mean || r.Run.Mean > r.Run.Max {
+		t.Errorf("mean is out of range: %+v", r.Run)
+	}
+}
+
+func TestCLI(t *testing.T) {
+	testCases := []struct {
+		Name   string
+		Args   []string
+		Stdin  string
+		Stdout string
+		Stderr string
+		Code   int
+	}{
+		{
+			Name:   "hello world",
+			Args:   []string{"../../example/hello-world.bf"},
+			Stdout: "Hello, world!",
+		},
+		{
+			Name:   "run command",
+			Args:   []string{"run", "../../example/hello-world.bf"},
+			Stdout: "Hello, world!",
+		},
+		{
+			Name:   "source from stdin",
+			Stdin:  "++++++++[>++++++++<-]>+.",
+			Stdout: "A",
+		},
+		{
+			Name:   "inline code",
+			Args:   []string{"-e", "++++++++[>++++++++<-]>+."},
+			Stdout: "A",
+		},
+		{
+			Name:   "inline code reads stdin",
+			Args:   []string{"-e", ",[.,]"},
+			Stdin:  "xyz",
+			Stdout: "xyz",
+		},
+		{
+			Name:   "input file",
+			Args:   []string{"-e", ",[.,]", "-input", "testdata/input.txt"},
+			Stdin:  "ignored",
+			Stdout: "abc\n",
+		},
+		{
+			Name:   "input string",
+			Args:   []string{"-input-string", "hi"},
+			Stdin:  ",[.,]",
+			Stdout: "hi",
+		},
+		{
+			Name:   "input string over the input separator",
+			Args:   []string{"-input-separator", "-input-string", "hi", "-e", ",[.,]!abc"},
+			Stdout: "hi",
+		},
+		{
+			Name:   "piped stdin over the input separator",
+			Args:   []string{"-input-separator", "-e", ",[.,]!abc"},
+			Stdin:  "piped",
+			Stdout: "piped",
+		},
+		{
+			Name:   "inline code with a file",
+			Args:   []string{"-e", "+", "../../example/hello-world.bf"},
+			Stderr: "-e can not be used with a file",
+			Code:   2,
+		},
+		{
+			Name:   "both input flags",
+			Args:   []string{"-input", "testdata/input.txt", "-input-string", "hi"},
+			Stderr: "-input and -input-string can not be used together",
+			Code:   2,
+		},
+		{
+			Name:   "missing input file",
+			Args:   []string{"-e", ",", "-input", "testdata/missing.txt"},
+			Stderr: "missing.txt",
+			Code:   1,
+		},
+		{
+			Name:   "unbuffered output",
+			Args:   []string{"-output-buffer-size", "0", "-flush", "newline", "../../example/hello-world.bf"},
+			Stdout: "Hello, world!",
+		},
+		{
+			Name:   "unknown flush policy",
+			Args:   []string{"-flush", "never", "../../example/hello-world.bf"},
+			Stderr: "unknown flush policy: never",
+			Code:   1,
+		},
+		{
+			Name:   "text stats",
+			Args:   []string{"-stats", "../../example/hello-world.bf"},
+			Stdout: "Hello, world!",
+			Stderr: "output bytes: 13\n",
+		},
+		{
+			Name:   "json stats",
+			Args:   []string{"-stats", "-stats-format", "json", "../../example/hello-world.bf"},
+			Stdout: "Hello, world!",
+			Stderr: `"output_bytes":13,`,
+		},
+		{
+			Name:   "pass stats",
+			Args:   []string{"-stats", "-disable-pass", "reset,scan", "-e", "+[-]x[>]"},
+			Stderr: "pass strip-comments: 6 -> 5 nodes\npass fold:           5 -> 5 nodes\n",
+		},
+		{
+			Name:   "json pass stats",
+			Args:   []string{"-stats", "-stats-format", "json", "-O1", "-e", "+[-]"},
+			Stderr: `"passes":[{"name":"strip-comments","nodes_before":3,"nodes_after":3},{"name":"fold","nodes_before":3,"nodes_after":3}]`,
+		},
+		{
+			Name:   "unoptimized",
+			Args:   []string{"-O0", "../../example/hello-world.bf"},
+			Stdout: "Hello, world!",
+		},
+		{
+			Name:   "unknown pass",
+			Args:   []string{"-disable-pass", "inline", "../../example/hello-world.bf"},
+			Stderr: "unknown optimization pass: inline",
+			Code:   2,
+		},
+		{
+			Name:   "stats after a failure",
+			Args:   []string{"-stats", "-stats-format", "json", "-max-steps", "10"},
+			Stdin:  "+[>+<]",
+			Stderr: `"steps":10,`,
+			Code:   4,
+		},
+		{
+			Name:   "syntax error",
+			Stdin:  "+[",
+			Stderr: "brainfxxk: ",
+			Code:   3,
+		},
+		{
+			Name:   "step limit",
+			Args:   []string{"-max-steps", "10"},
+			Stdin:  "+[>+<]",
+			Stderr: "brainfxxk: ",
+			Code:   4,
+		},
+		{
+			Name:   "unknown flag",
+			Args:   []string{"-no-such-flag"},
+			Stderr: "Usage: brainfxxk run",
+			Code:   2,
+		},
+		{
+			Name:   "unknown bench format",
+			Args:   []string{"bench", "-format", "xml"},
+			Stderr: "unknown bench format: xml",
+			Code:   2,
+		},
+		{
+			Name:   "unknown stats format",
+			Args:   []string{"-stats-format", "xml"},
+			Stderr: "unknown stats format: xml",
+			Code:   2,
+		},
+		{
+			Name: "check",
+			Args: []string{"check", "../../example/hello-world.bf", "../../example/prime.bf"},
+		},
+		{
+			Name:   "check syntax error",
+			Args:   []string{"check", "-"},
+			Stdin:  "]",
+			Stderr: "-: ",
+			Code:   3,
+		},
+		{
+			Name:   "golden files",
+			Args:   []string{"test", "../../example", "../../golden/testdata"},
+			Stdout: "5 passed, 0 failed, 1 skipped\n",
+		},
+		{
+			Name:   "repl",
+			Args:   []string{"repl"},
+			Stdin:  "++++++++[>++++++++\n<-]>+.\n",
+			Stdout: "bf> ... A\nbf> \n",
+		},
+		{
+			Name:   "repl procedure",
+			Args:   []string{"repl", "-pbrain"},
+			Stdin:  "(\n++++++++[>++++++++<-]>+.<):\n",
+			Stdout: "bf> ... A\nbf> \n",
+		},
+		{
+			Name:   "repl procedure from an earlier line",
+			Args:   []string{"repl", "-pbrain"},
+			Stdin:  "(++++++++[>++++++++<-]>+.<)\n+-:\n",
+			Stdout: "bf> bf> A\nbf> \n",
+		},
+		{
+			Name:   "repl dialect",
+			Args:   []string{"repl", "-dialect", "pikalang"},
+			Stdin:  "pi [not a loop\npikachu\n",
+			Stdout: "bf> bf> \x01\nbf> \n",
+		},
+		{
+			Name:   "fmt",
+			Args:   []string{"fmt"},
+			Stdin:  "+[->+<]",
+			Stdout: "+[->+<]\n",
+		},
+		{
+			Name:   "minify",
+			Args:   []string{"minify"},
+			Stdin:  "a + b [ - ] c",
+			Stdout: "+[-]",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Name, func(t *testing.T) {
+			res := runCLI(t, tc.Stdin, tc.Args...)
+			if res.code != tc.Code {
+				t.Errorf("exit code: expected %d, got %d\nstderr: %s", tc.Code, res.code, res.stderr)
+			}
+			if res.stdout != tc.Stdout {
+				t.Errorf("stdout: expected %q, got %q", tc.Stdout, res.stdout)
+			}
+			if !strings.Contains(res.stderr, tc.Stderr) {
+				t.Errorf("stderr: expected to contain %q, got %q", tc.Stderr, res.stderr)
+			}
+			if tc.Stderr == "" && res.stderr != "" {
+				t.Errorf("stderr: expected nothing, got %q", res.stderr)
+			}
+		})
+	}
+}
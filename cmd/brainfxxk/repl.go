@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rosylilly/brainfxxk/ast"
+	"github.com/rosylilly/brainfxxk/interpreter"
+	"github.com/rosylilly/brainfxxk/lexer"
+	"github.com/rosylilly/brainfxxk/parser"
+)
+
+const replHelp = `Enter Brainfuck code to run it on the tape. Lines are joined until the
+brackets balance. Input for ',' is read from the following lines.
+
+commands:
+  :tape [RADIUS]      print the cells around the pointer
+  :reset              clear the tape and move the pointer to 0
+  :load FILE          run a file on the tape
+  :overflow [on|off]  toggle raising errors on overflow
+  :help               show this help
+  :quit               exit
+`
+
+func replCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("repl")
+	rf := addRuntimeFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return usageError(fs, "repl takes no files")
+	}
+
+	c, err := rf.load()
+	if err != nil {
+		return err
+	}
+	return replLoop(ctx, os.Stdin, os.Stdout, c)
+}
+
+// replLoop reads code and ',' input from the same reader, so input typed
+// after a line is consumed by the code on it.
+func replLoop(ctx context.Context, r io.Reader, w io.Writer, c *interpreter.Config) error {
+	in := bufio.NewReader(r)
+	out := &lastByteWriter{w: w}
+	c.Reader = in
+	c.Writer = out
+	ip := interpreter.NewInterpreter(&ast.Program{}, c)
+
+	code := ""
+	for {
+		prompt := "bf> "
+		if code != "" {
+			prompt = "... "
+		}
+		if out.last != 0 && out.last != '\n' {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprint(out, prompt)
+
+		line, err := in.ReadString('\n')
+		if errors.Is(err, io.EOF) && line == "" {
+			fmt.Fprintln(out)
+			return nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		out.last = '\n'
+
+		if code == "" && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			quit, err := replMeta(ctx, ip, out, strings.Fields(line))
+			if err != nil {
+				fmt.Fprintf(out, "error: %v\n", err)
+			}
+			if quit {
+				return nil
+			}
+			continue
+		}
+
+		code += line
+		if depth := bracketDepth(code, &c.Syntax); depth > 0 {
+			continue
+		}
+
+		p, err := parser.ParseWithConfig(strings.NewReader(code), &c.Syntax)
+		code = ""
+		if err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+			continue
+		}
+		if _, err := ip.Eval(ctx, p); err != nil {
+			fmt.Fprintf(out, "\nerror: %v\n", err)
+		}
+		// the line break after typed input is not a new line of code.
+		if ip.InputBytes() > 0 && in.Buffered() > 0 {
+			if b, _ := in.Peek(1); b[0] == '\n' {
+				in.ReadByte()
+			}
+		}
+	}
+}
+
+func replMeta(ctx context.Context, ip *interpreter.Interpreter, out io.Writer, fields []string) (bool, error) {
+	switch fields[0] {
+	case ":tape", ":t":
+		radius := 5
+		if len(fields) > 1 {
+			var err error
+			if radius, err = strconv.Atoi(fields[1]); err != nil {
+				return false, err
+			}
+		}
+		return false, ip.WriteTape(out, radius)
+	case ":reset":
+		clear(ip.Memory)
+		ip.Pointer = 0
+	case ":load":
+		if len(fields) != 2 {
+			return false, fmt.Errorf("%s requires a file", fields[0])
+		}
+		src, err := os.ReadFile(fields[1])
+		if err != nil {
+			return false, err
+		}
+		p, err := parseSource(src, &ip.Config.Syntax)
+		if err != nil {
+			return false, err
+		}
+		_, err = ip.Eval(ctx, p)
+		return false, err
+	case ":overflow":
+		raise := !ip.Config.RaiseErrorOnOverflow
+		if len(fields) > 1 {
+			switch fields[1] {
+			case "on":
+				raise = true
+			case "off":
+				raise = false
+			default:
+				return false, fmt.Errorf("%s takes on or off", fields[0])
+			}
+		}
+		ip.Config.RaiseErrorOnOverflow = raise
+		fmt.Fprintf(out, "raise error on overflow: %v\n", raise)
+	case ":help", ":h":
+		fmt.Fprint(out, replHelp)
+	case ":quit", ":q":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown command: %s", fields[0])
+	}
+	return false, nil
+}
+
+// bracketDepth returns how many loops and procedures the code leaves open.
+func bracketDepth(code string, c *lexer.Config) int {
+	l := lexer.NewLexerWithConfig(strings.NewReader(code), c)
+	depth := 0
+	for {
+		token, err := l.Next()
+		if err != nil {
+			return depth
+		}
+		switch token.Type {
+		case lexer.WhileStartToken, lexer.ProcedureStartToken:
+			depth++
+		case lexer.WhileEndToken, lexer.ProcedureEndToken:
+			depth--
+		case lexer.InputSeparatorToken:
+			// the rest is input.
+			return depth
+		}
+	}
+}
+
+// lastByteWriter remembers the last byte written so that prompts start on a
+// fresh line.
+type lastByteWriter struct {
+	w    io.Writer
+	last byte
+}
+
+func (lw *lastByteWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		lw.last = p[len(p)-1]
+	}
+	return lw.w.Write(p)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rosylilly/brainfxxk/interpreter"
+)
+
+type runOptions struct {
+	stats         bool
+	statsFormat   string
+	profile       bool
+	profileSource bool
+	pprofFile     string
+	snapshotFile  string
+	resumeFile    string
+	traceFile     string
+	traceFilter   string
+	traceRange    string
+	traceSample   int
+}
+
+func runCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("run")
+	rf := addRuntimeFlags(fs)
+	iof := addIOFlags(fs)
+	astInfo := fs.Bool("ast-info", false, "show ast info instead of running, deprecated: use the ast command")
+	o := &runOptions{statsFormat: "text", traceFilter: interpreter.TraceAll.String(), traceSample: 1}
+	fs.BoolVar(&o.stats, "stats", o.stats, "write statistics of the run to stderr")
+	fs.StringVar(&o.statsFormat, "stats-format", o.statsFormat, "statistics format: text or json")
+	fs.BoolVar(&o.profile, "profile", o.profile, "write the hottest instructions to stderr")
+	fs.BoolVar(&o.profileSource, "profile-source", o.profileSource, "write the source annotated with steps per line to stderr")
+	fs.StringVar(&o.pprofFile, "pprof", o.pprofFile, "write a pprof profile to this file")
+	fs.StringVar(&o.snapshotFile, "snapshot", o.snapshotFile, "save the machine to this file when interrupted")
+	fs.StringVar(&o.resumeFile, "resume", o.resumeFile, "resume the machine saved in this file")
+	fs.StringVar(&o.traceFile, "trace", o.traceFile, "trace executed instructions to this file, or stderr with -")
+	fs.StringVar(&o.traceFilter, "trace-filter", o.traceFilter, "trace only: all, loops or io")
+	fs.StringVar(&o.traceRange, "trace-range", o.traceRange, "trace only source positions START:END")
+	fs.IntVar(&o.traceSample, "trace-sample", o.traceSample, "trace every Nth matching instruction")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return usageError(fs, "run takes one file")
+	}
+	if err := iof.validate(fs); err != nil {
+		return err
+	}
+	if o.statsFormat != "text" && o.statsFormat != "json" {
+		return usageError(fs, "unknown stats format: %s", o.statsFormat)
+	}
+
+	c, err := rf.load()
+	if err != nil {
+		return err
+	}
+	c.AstInfo = *astInfo
+
+	filename, src, err := iof.readSource(fs)
+	if err != nil {
+		return err
+	}
+
+	// stdin only supplies input when it is not the source or a terminal,
+	// otherwise the input embedded after '!' is used.
+	sourceFromStdin := fs.NArg() == 0 && iof.expr == ""
+	if c.Syntax.InputSeparator && !iof.hasInput() && (sourceFromStdin || isTerminal(os.Stdin)) {
+		c.Reader = nil
+	}
+	closeIO, err := iof.open(c)
+	if err != nil {
+		return err
+	}
+	defer closeIO()
+
+	closeTrace := func() error { return nil }
+	if o.traceFile != "" {
+		c.Trace, closeTrace, err = o.newTracer()
+		if err != nil {
+			return err
+		}
+	}
+	if o.profile || o.profileSource || o.pprofFile != "" {
+		c.Profiler = interpreter.NewProfiler()
+		c.Profiler.Filename = filename
+	}
+
+	before := time.Now()
+	ip, count, err := o.run(ctx, src, c)
+	elapsed := time.Since(before)
+	if terr := closeTrace(); terr != nil && err == nil {
+		err = terr
+	}
+	if c.Profiler != nil {
+		if perr := o.writeProfile(c.Profiler, src); perr != nil && err == nil {
+			err = perr
+		}
+	}
+	if o.stats && ip != nil {
+		if serr := newStats(ip, count, elapsed).write(os.Stderr, o.statsFormat); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	if cerr := closeIO(); cerr != nil && err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (o *runOptions) run(ctx context.Context, src []byte, c *interpreter.Config) (*interpreter.Interpreter, int, error) {
+	ip, err := interpreter.Load(bytes.NewReader(src), c)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if o.resumeFile != "" {
+		fp, err := os.Open(o.resumeFile)
+		if err != nil {
+			return ip, 0, err
+		}
+		defer fp.Close()
+
+		snapshot, err := interpreter.ReadSnapshot(fp)
+		if err != nil {
+			return ip, 0, err
+		}
+		if err := ip.Restore(snapshot); err != nil {
+			return ip, 0, err
+		}
+	}
+
+	count, err := ip.Run(ctx)
+	if o.snapshotFile != "" && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		if serr := o.saveSnapshot(ip); serr != nil {
+			return ip, count, serr
+		}
+		fmt.Fprintf(os.Stderr, "\nsaved to %s\n", o.snapshotFile)
+	}
+	return ip, count, err
+}
+
+func (o *runOptions) saveSnapshot(ip *interpreter.Interpreter) error {
+	snapshot, err := ip.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	fp, err := os.Create(o.snapshotFile)
+	if err != nil {
+		return err
+	}
+	if err := snapshot.Write(fp); err != nil {
+		fp.Close()
+		return err
+	}
+	return fp.Close()
+}
+
+// newTracer returns the tracer configured by the flags and a function that
+// flushes and closes its output.
+func (o *runOptions) newTracer() (*interpreter.Tracer, func() error, error) {
+	filter, err := interpreter.ParseTraceFilter(o.traceFilter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var start, end int
+	if o.traceRange != "" {
+		if _, err := fmt.Sscanf(o.traceRange, "%d:%d", &start, &end); err != nil {
+			return nil, nil, fmt.Errorf("invalid trace range %q: %w", o.traceRange, err)
+		}
+	}
+
+	fp := os.Stderr
+	if o.traceFile != "-" {
+		if fp, err = os.Create(o.traceFile); err != nil {
+			return nil, nil, err
+		}
+	}
+	w := bufio.NewWriter(fp)
+
+	tracer := interpreter.NewTracer(w)
+	tracer.Filter = filter
+	tracer.StartPos = start
+	tracer.EndPos = end
+	tracer.Sample = o.traceSample
+	return tracer, func() error {
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if fp == os.Stderr {
+			return nil
+		}
+		return fp.Close()
+	}, nil
+}
+
+func (o *runOptions) writeProfile(p *interpreter.Profiler, src []byte) error {
+	if o.profile {
+		if err := p.WriteReport(os.Stderr, 20); err != nil {
+			return err
+		}
+	}
+	if o.profileSource {
+		if err := p.WriteAnnotatedSource(os.Stderr, src); err != nil {
+			return err
+		}
+	}
+	if o.pprofFile != "" {
+		fp, err := os.Create(o.pprofFile)
+		if err != nil {
+			return err
+		}
+		defer fp.Close()
+		return p.WritePprof(fp)
+	}
+	return nil
+}
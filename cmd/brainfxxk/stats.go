@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/rosylilly/brainfxxk/interpreter"
+)
+
+type stats struct {
+	Elapsed     time.Duration `json:"elapsed_ns"`
+	Count       int           `json:"count"`
+	Steps       int           `json:"steps"`
+	Nodes       int           `json:"nodes"`
+	MaxPointer  int           `json:"max_pointer"`
+	InputBytes  int           `json:"input_bytes"`
+	OutputBytes int           `json:"output_bytes"`
+	Passes      []passStats   `json:"passes"`
+}
+
+type passStats struct {
+	Name        string `json:"name"`
+	NodesBefore int    `json:"nodes_before"`
+	NodesAfter  int    `json:"nodes_after"`
+}
+
+func newStats(ip *interpreter.Interpreter, count int, elapsed time.Duration) *stats {
+	passes := []passStats{}
+	for _, p := range ip.PassStats() {
+		passes = append(passes, passStats{Name: p.Name, NodesBefore: p.NodesBefore, NodesAfter: p.NodesAfter})
+	}
+	return &stats{
+		Elapsed:     elapsed,
+		Count:       count,
+		Steps:       ip.Steps(),
+		Nodes:       ip.Nodes(),
+		MaxPointer:  ip.MaxPointer(),
+		InputBytes:  ip.InputBytes(),
+		OutputBytes: ip.OutputBytes(),
+		Passes:      passes,
+	}
+}
+
+func (s *stats) write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		return json.NewEncoder(w).Encode(s)
+	case "text":
+		_, err := fmt.Fprintf(w, "elapsed:      %v\ncount:        %d\nsteps:        %d\nnodes:        %d\nmax pointer:  %d\ninput bytes:  %d\noutput bytes: %d\n",
+			s.Elapsed, s.Count, s.Steps, s.Nodes, s.MaxPointer, s.InputBytes, s.OutputBytes)
+		for _, p := range s.Passes {
+			if err != nil {
+				break
+			}
+			_, err = fmt.Fprintf(w, "pass %-15s %d -> %d nodes\n", p.Name+":", p.NodesBefore, p.NodesAfter)
+		}
+		return err
+	}
+	return fmt.Errorf("unknown stats format: %s", format)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rosylilly/brainfxxk/golden"
+)
+
+const defaultTestMaxSteps = 100_000_000
+
+func testCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("test")
+	rf := addRuntimeFlags(fs)
+	rf.config.MaxSteps = defaultTestMaxSteps
+	fs.Lookup("max-steps").DefValue = strconv.Itoa(defaultTestMaxSteps)
+	update := fs.Bool("update", false, "write the actual output as the expected output")
+	verbose := fs.Bool("v", false, "also list passing programs")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	c, err := rf.load()
+	if err != nil {
+		return err
+	}
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	cases, err := golden.Discover(paths...)
+	if err != nil {
+		return err
+	}
+
+	gc := &golden.Config{Interpreter: *c, Update: *update}
+	passed, failed, skipped := 0, 0, 0
+	for _, tc := range cases {
+		before := time.Now()
+		r := golden.Run(ctx, tc, gc)
+		elapsed := time.Since(before).Round(time.Millisecond)
+
+		switch {
+		case errors.Is(r.Err, golden.ErrNoExpectation):
+			skipped++
+			if *verbose {
+				fmt.Printf("?    %s\t[no expected output]\n", tc.Path)
+			}
+		case r.Updated:
+			passed++
+			fmt.Printf("upd  %s\t%v\n", tc.Path, elapsed)
+		case r.Passed():
+			passed++
+			if *verbose {
+				fmt.Printf("ok   %s\t%v\n", tc.Path, elapsed)
+			}
+		default:
+			failed++
+			fmt.Printf("FAIL %s\t%v\n", tc.Path, elapsed)
+			if r.Err != nil {
+				fmt.Printf("    %v\n", r.Err)
+			}
+			if r.Diff != "" {
+				fmt.Print(r.Diff)
+			}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	fmt.Printf("%d passed, %d failed, %d skipped\n", passed, failed, skipped)
+	if failed > 0 {
+		return &exitError{code: exitRuntimeError}
+	}
+	return nil
+}
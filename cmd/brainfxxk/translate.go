@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/rosylilly/brainfxxk/lexer"
+)
+
+func translateCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("translate")
+	from := fs.String("from", lexer.Standard.Name, "dialect name or JSON dialect file of the source")
+	to := fs.String("to", lexer.Standard.Name, "dialect name or JSON dialect file to translate into")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return usageError(fs, "translate takes one file")
+	}
+
+	fromDialect, err := loadDialect(*from)
+	if err != nil {
+		return err
+	}
+	toDialect, err := loadDialect(*to)
+	if err != nil {
+		return err
+	}
+
+	var source io.ReadCloser = os.Stdin
+	if fs.NArg() > 0 {
+		fp, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		source = fp
+	}
+	defer source.Close()
+
+	return lexer.Translate(os.Stdout, source, fromDialect, toDialect)
+}
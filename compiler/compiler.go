@@ -0,0 +1,92 @@
+package compiler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rosylilly/brainfxxk/ast"
+)
+
+var (
+	ErrUnsupported = fmt.Errorf("unsupported expression")
+)
+
+type Config struct {
+	MemorySize int
+}
+
+// CompileC writes a C program equivalent to the optimized program. Like the
+// interpreter, the program stops when input ends.
+func CompileC(w io.Writer, p *ast.Program, c *Config) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `#include <stdio.h>
+
+static unsigned char m[%d];
+
+int main(void) {
+	unsigned char *p = m;
+	int c;
+
+`, c.MemorySize)
+	if err := compileC(bw, p.Expressions, 1); err != nil {
+		return err
+	}
+	bw.WriteString(`
+	(void)c;
+	return 0;
+}
+`)
+	return bw.Flush()
+}
+
+func compileC(w *bufio.Writer, exprs []ast.Expression, depth int) error {
+	indent := strings.Repeat("\t", depth)
+	for _, expr := range exprs {
+		var line string
+		switch e := expr.(type) {
+		case *ast.Comment, *ast.DebugExpression:
+			continue
+		case *ast.PointerIncrementExpression:
+			line = "p++;"
+		case *ast.PointerDecrementExpression:
+			line = "p--;"
+		case *ast.MultiplePointerIncrementExpression:
+			line = fmt.Sprintf("p += %d;", e.Count)
+		case *ast.MultiplePointerDecrementExpression:
+			line = fmt.Sprintf("p -= %d;", e.Count)
+		case *ast.PointerMoveExpression:
+			line = fmt.Sprintf("p += %d;", e.Count)
+		case *ast.ValueIncrementExpression:
+			line = "(*p)++;"
+		case *ast.ValueDecrementExpression:
+			line = "(*p)--;"
+		case *ast.MultipleValueIncrementExpression:
+			line = fmt.Sprintf("*p += %d;", e.Count)
+		case *ast.MultipleValueDecrementExpression:
+			line = fmt.Sprintf("*p -= %d;", e.Count)
+		case *ast.ValueChangeExpression:
+			line = fmt.Sprintf("*p += %d;", e.Count)
+		case *ast.ValueResetExpression:
+			line = "*p = 0;"
+		case *ast.ZeroSearchExpression:
+			line = fmt.Sprintf("while (*p) p += %d;", e.SearchWindow)
+		case *ast.OutputExpression:
+			line = "putchar(*p);"
+		case *ast.InputExpression:
+			line = "if ((c = getchar()) == EOF) return 0; *p = c;"
+		case *ast.WhileExpression:
+			fmt.Fprintf(w, "%swhile (*p) {\n", indent)
+			if err := compileC(w, e.Body, depth+1); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%s}\n", indent)
+			continue
+		default:
+			return fmt.Errorf("%w: %s at %d", ErrUnsupported, expr, expr.StartPos())
+		}
+		fmt.Fprintf(w, "%s%s\n", indent, line)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package compiler_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rosylilly/brainfxxk/compiler"
+	"github.com/rosylilly/brainfxxk/lexer"
+	"github.com/rosylilly/brainfxxk/optimizer"
+	"github.com/rosylilly/brainfxxk/parser"
+)
+
+func TestCompileC(t *testing.T) {
+	p, err := parser.Parse(strings.NewReader("++[>+++<-]>[-]>>[<],."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err = optimizer.NewOptimizer().Optimize(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := &bytes.Buffer{}
+	if err := compiler.CompileC(w, p, &compiler.Config{MemorySize: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"static unsigned char m[100];",
+		"\t*p += 2;\n\twhile (*p) {\n\t\tp += 1;\n\t\t*p += 3;\n",
+		"\t*p = 0;\n",
+		"\twhile (*p) p += -1;\n",
+		"putchar(*p);",
+	} {
+		if !strings.Contains(w.String(), expected) {
+			t.Errorf("expected %q in:\n%s", expected, w.String())
+		}
+	}
+
+	p, err = parser.ParseWithConfig(strings.NewReader("(+):"), &lexer.Config{Procedures: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := compiler.CompileC(&bytes.Buffer{}, p, &compiler.Config{MemorySize: 100}); !errors.Is(err, compiler.ErrUnsupported) {
+		t.Errorf("got: %v, expected: %v", err, compiler.ErrUnsupported)
+	}
+}
+
+func TestCompileCRun(t *testing.T) {
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("no C compiler")
+	}
+
+	src, err := os.ReadFile("../example/hello-world.bf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := parser.Parse(bytes.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err = optimizer.NewOptimizer().Optimize(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	fp, err := os.Create(filepath.Join(dir, "hello.c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := compiler.CompileC(fp, p, &compiler.Config{MemorySize: 30000}); err != nil {
+		t.Fatal(err)
+	}
+	fp.Close()
+
+	bin := filepath.Join(dir, "hello")
+	if out, err := exec.Command(cc, "-o", bin, fp.Name()).CombinedOutput(); err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	out, err := exec.Command(bin).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "Hello, world!" {
+		t.Errorf("got: %q", out)
+	}
+}
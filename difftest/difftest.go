@@ -0,0 +1,311 @@
+package difftest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/rosylilly/brainfxxk/compiler"
+	"github.com/rosylilly/brainfxxk/interpreter"
+	"github.com/rosylilly/brainfxxk/parser"
+)
+
+var (
+	ErrPanic = fmt.Errorf("panic")
+)
+
+// errorKinds are compared instead of error messages, which hold positions
+// that differ between backends.
+var errorKinds = []error{
+	parser.ErrInvalidSyntax,
+	interpreter.ErrInputFinished,
+	interpreter.ErrMemoryOverflow,
+	interpreter.ErrUndefinedProcedure,
+	interpreter.ErrCallDepthExceeded,
+	interpreter.ErrStepLimitExceeded,
+	interpreter.ErrOutputLimitExceeded,
+	interpreter.ErrInputLimitExceeded,
+	interpreter.ErrMemoryLimitExceeded,
+	ErrPanic,
+}
+
+// Outcome is the state a backend leaves behind. Memory is nil for backends
+// that can't report the tape, and Steps and Count are -1 when they can't
+// count.
+type Outcome struct {
+	Output  []byte
+	Memory  []byte
+	Pointer int
+	Steps   int
+	// Count is the number of expressions run, as Interpreter.Run returns
+	// it. An optimized program runs fewer expressions than the reference,
+	// never more.
+	Count int
+	Err   error
+}
+
+type Backend interface {
+	Name() string
+	Run(ctx context.Context, src []byte, input []byte, c *interpreter.Config) *Outcome
+}
+
+type interpreterBackend struct {
+	name        string
+	unoptimized bool
+}
+
+var (
+	// Raw runs the parsed program without optimizing it, the reference the
+	// others are compared with.
+	Raw Backend = &interpreterBackend{name: "raw", unoptimized: true}
+	// Optimized runs the program the way Run does.
+	Optimized Backend = &interpreterBackend{name: "optimized"}
+)
+
+func (b *interpreterBackend) Name() string {
+	return b.name
+}
+
+func (b *interpreterBackend) Run(ctx context.Context, src []byte, input []byte, c *interpreter.Config) (o *Outcome) {
+	out := &bytes.Buffer{}
+	ic := *c
+	ic.Reader = bytes.NewReader(input)
+	ic.Writer = out
+	ic.Unoptimized = b.unoptimized
+
+	o = &Outcome{Steps: -1, Count: -1}
+	defer func() {
+		if r := recover(); r != nil {
+			o.Output = out.Bytes()
+			o.Memory = nil
+			o.Err = fmt.Errorf("%w: %v", ErrPanic, r)
+		}
+	}()
+
+	ip, err := interpreter.Load(bytes.NewReader(src), &ic)
+	if err != nil {
+		o.Err = err
+		return o
+	}
+	o.Count, o.Err = ip.Run(ctx)
+	o.Output = out.Bytes()
+	o.Memory = ip.Memory
+	o.Pointer = ip.Pointer
+	o.Steps = ip.Steps()
+	return o
+}
+
+type cBackend struct {
+	cc string
+}
+
+// C compiles the program with the C compiler cc and reports its output only.
+// It runs without limits.
+func C(cc string) Backend {
+	return &cBackend{cc: cc}
+}
+
+func (b *cBackend) Name() string {
+	return "c"
+}
+
+func (b *cBackend) Run(ctx context.Context, src []byte, input []byte, c *interpreter.Config) *Outcome {
+	o := &Outcome{Steps: -1, Count: -1}
+	p, err := parser.ParseWithConfig(bytes.NewReader(src), &c.Syntax)
+	if err != nil {
+		o.Err = err
+		return o
+	}
+
+	dir, err := os.MkdirTemp("", "difftest")
+	if err != nil {
+		o.Err = err
+		return o
+	}
+	defer os.RemoveAll(dir)
+
+	code := &bytes.Buffer{}
+	if err := compiler.CompileC(code, p, &compiler.Config{MemorySize: c.MemorySize}); err != nil {
+		o.Err = err
+		return o
+	}
+	source := filepath.Join(dir, "main.c")
+	binary := filepath.Join(dir, "main")
+	if err := os.WriteFile(source, code.Bytes(), 0o644); err != nil {
+		o.Err = err
+		return o
+	}
+	if out, err := exec.CommandContext(ctx, b.cc, "-O1", "-o", binary, source).CombinedOutput(); err != nil {
+		o.Err = fmt.Errorf("%s: %w: %s", b.cc, err, out)
+		return o
+	}
+
+	cmd := exec.CommandContext(ctx, binary)
+	cmd.Stdin = bytes.NewReader(input)
+	o.Output, o.Err = cmd.Output()
+	return o
+}
+
+// Mismatch is a field of an outcome that differs from the reference.
+type Mismatch struct {
+	Backend  string
+	Field    string
+	Expected string
+	Got      string
+}
+
+func (m *Mismatch) String() string {
+	return fmt.Sprintf("%s: %s: expected %s, got %s", m.Backend, m.Field, m.Expected, m.Got)
+}
+
+type Report struct {
+	Outcomes   map[string]*Outcome
+	Mismatches []*Mismatch
+}
+
+func (r *Report) OK() bool {
+	return len(r.Mismatches) == 0
+}
+
+func (r *Report) String() string {
+	lines := make([]string, 0, len(r.Mismatches))
+	for _, m := range r.Mismatches {
+		lines = append(lines, m.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Compare runs the program on every backend and compares their outcomes with
+// the first one. Raw and Optimized are used without backends.
+func Compare(ctx context.Context, src []byte, input []byte, c *interpreter.Config, backends ...Backend) *Report {
+	if len(backends) == 0 {
+		backends = []Backend{Raw, Optimized}
+	}
+
+	r := &Report{Outcomes: map[string]*Outcome{}}
+	var reference Backend
+	var expected *Outcome
+	for _, b := range backends {
+		o := b.Run(ctx, src, input, c)
+		r.Outcomes[b.Name()] = o
+		// a panic is a bug even when every backend panics.
+		if errors.Is(o.Err, ErrPanic) {
+			r.Mismatches = append(r.Mismatches, &Mismatch{Backend: b.Name(), Field: "panic", Expected: "none", Got: o.Err.Error()})
+		}
+		if expected == nil {
+			reference, expected = b, o
+			continue
+		}
+
+		e, g := expected, o
+		if steps, ok := commonSteps(expected, o); ok {
+			// optimized expressions charge their steps at once, so runs cut
+			// by the step limit stop in different places. The one that got
+			// further runs again to where the other stopped.
+			sc := *c
+			sc.MaxSteps = steps
+			if e.Steps > steps {
+				e = reference.Run(ctx, src, input, &sc)
+			}
+			if g.Steps > steps {
+				g = b.Run(ctx, src, input, &sc)
+			}
+		}
+		r.compare(b.Name(), e, g)
+	}
+	return r
+}
+
+// commonSteps returns the steps both runs cut by the step limit got through.
+func commonSteps(expected, got *Outcome) (int, bool) {
+	if !errors.Is(expected.Err, interpreter.ErrStepLimitExceeded) || !errors.Is(got.Err, interpreter.ErrStepLimitExceeded) {
+		return 0, false
+	}
+	steps := min(expected.Steps, got.Steps)
+	// no steps at all would run again without a limit.
+	return steps, steps > 0
+}
+
+func (r *Report) compare(name string, expected, got *Outcome) {
+	mismatch := func(field string, e, g any) {
+		r.Mismatches = append(r.Mismatches, &Mismatch{
+			Backend:  name,
+			Field:    field,
+			Expected: fmt.Sprintf("%v", e),
+			Got:      fmt.Sprintf("%v", g),
+		})
+	}
+
+	stepLimited := errors.Is(expected.Err, interpreter.ErrStepLimitExceeded)
+	if stepLimited && got.Steps < 0 {
+		// the backend can't count steps and ran to the end.
+		if !bytes.HasPrefix(got.Output, expected.Output) {
+			mismatch("output prefix", fmt.Sprintf("%q", expected.Output), fmt.Sprintf("%q", got.Output))
+		}
+		return
+	}
+	if ek, gk := errorKind(expected.Err), errorKind(got.Err); ek != gk {
+		mismatch("error", expected.Err, got.Err)
+	}
+	if stepLimited && expected.Steps != got.Steps {
+		// the runs could not be stopped in the same place, so only their
+		// output is compared, one has to start with the other.
+		if !bytes.HasPrefix(expected.Output, got.Output) && !bytes.HasPrefix(got.Output, expected.Output) {
+			mismatch("output prefix", fmt.Sprintf("%q", expected.Output), fmt.Sprintf("%q", got.Output))
+		}
+		return
+	}
+
+	if !bytes.Equal(expected.Output, got.Output) {
+		mismatch("output", fmt.Sprintf("%q", expected.Output), fmt.Sprintf("%q", got.Output))
+	}
+	if expected.Memory != nil && got.Memory != nil {
+		if cell, ok := firstDifference(expected.Memory, got.Memory); ok {
+			mismatch(fmt.Sprintf("memory[%d]", cell), cellAt(expected.Memory, cell), cellAt(got.Memory, cell))
+		}
+		if expected.Pointer != got.Pointer {
+			mismatch("pointer", expected.Pointer, got.Pointer)
+		}
+	}
+	if expected.Steps >= 0 && got.Steps >= 0 && expected.Steps != got.Steps {
+		mismatch("steps", expected.Steps, got.Steps)
+	}
+	if expected.Count >= 0 && got.Count > expected.Count {
+		mismatch("count", fmt.Sprintf("at most %d", expected.Count), got.Count)
+	}
+}
+
+func errorKind(err error) string {
+	if err == nil {
+		return ""
+	}
+	for _, kind := range errorKinds {
+		if errors.Is(err, kind) {
+			return kind.Error()
+		}
+	}
+	return err.Error()
+}
+
+// firstDifference returns the first cell that differs. The tape may have
+// grown further on one backend, so missing cells count as zero.
+func firstDifference(expected, got []byte) (int, bool) {
+	for cell := 0; cell < max(len(expected), len(got)); cell++ {
+		if cellAt(expected, cell) != cellAt(got, cell) {
+			return cell, true
+		}
+	}
+	return 0, false
+}
+
+func cellAt(memory []byte, cell int) byte {
+	if cell < len(memory) {
+		return memory[cell]
+	}
+	return 0
+}
@@ -0,0 +1,203 @@
+package difftest_test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rosylilly/brainfxxk/difftest"
+	"github.com/rosylilly/brainfxxk/interpreter"
+)
+
+const maxSteps = 100_000
+
+func testConfig() *interpreter.Config {
+	return &interpreter.Config{MemorySize: 1024, MaxSteps: maxSteps}
+}
+
+func TestCompare(t *testing.T) {
+	testCases := []struct {
+		name   string
+		source string
+		input  string
+	}{
+		{name: "value changes", source: "+++--+>++<-"},
+		{name: "pointer moves", source: ">>><<>+>>>>><<+"},
+		{name: "reset", source: "+++++[-]>++[-]+"},
+		{name: "clear loops", source: "+++++[+]>+++[---]>++[-x-+]>+[[-]>]<<<<+++[+++]"},
+		{name: "even clear loop", source: "++++[--]"},
+		{name: "endless clear loop", source: "+[--]"},
+		{name: "zero search", source: ">+>+>+>>+<<<<[>]<[<]>>>"},
+		{name: "zero search off the tape", source: "+[<]"},
+		{name: "zero search back and forth", source: "+>+>+>+<<<[>><]<[<x<>]"},
+		{name: "zero search by two", source: "+>>+>>+>>>>+<<<<<<<<[>>]"},
+		{name: "nested loops", source: "++[>+++[>+<-]<-]>>."},
+		{name: "input", source: ",[.,]", input: "hello"},
+		{name: "input end", source: ",+.,+.,+.", input: "a"},
+		{name: "step limit", source: "+[>+<]"},
+		{name: "step limit in a clear loop", source: "+[>++++++++++[-]<]"},
+		{name: "moves cancelling out", source: "+[<>]"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := difftest.Compare(context.Background(), []byte(tc.source), []byte(tc.input), testConfig())
+			if !r.OK() {
+				t.Error(r)
+			}
+		})
+	}
+}
+
+// brokenBackend runs the program optimized and then breaks the outcome.
+type brokenBackend struct {
+	breaks func(o *difftest.Outcome)
+}
+
+func (b *brokenBackend) Name() string {
+	return "broken"
+}
+
+func (b *brokenBackend) Run(ctx context.Context, src []byte, input []byte, c *interpreter.Config) *difftest.Outcome {
+	o := difftest.Optimized.Run(ctx, src, input, c)
+	b.breaks(o)
+	return o
+}
+
+func TestCompareMismatch(t *testing.T) {
+	testCases := []struct {
+		name     string
+		source   string
+		breaks   func(o *difftest.Outcome)
+		expected string
+	}{
+		{
+			name:     "count",
+			source:   "+++[-]",
+			breaks:   func(o *difftest.Outcome) { o.Count = 10 },
+			expected: "broken: count: expected at most 7, got 10",
+		},
+		{
+			// the optimized run stops before "[-]", which would go past
+			// the limit, and is compared where it stopped.
+			name:     "memory at the step limit",
+			source:   "++++++++[-]",
+			breaks:   func(o *difftest.Outcome) { o.Memory[0]++ },
+			expected: "broken: memory[0]: expected 8, got 9",
+		},
+		{
+			name:     "output at the step limit",
+			source:   "+.[.]",
+			breaks:   func(o *difftest.Outcome) { o.Output = []byte("x") },
+			expected: "broken: output: expected",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := testConfig()
+			c.MaxSteps = 12
+			r := difftest.Compare(context.Background(), []byte(tc.source), nil, c, difftest.Raw, &brokenBackend{breaks: tc.breaks})
+			if !strings.Contains(r.String(), tc.expected) {
+				t.Errorf("got: %q, expected to contain: %q", r, tc.expected)
+			}
+		})
+	}
+}
+
+func TestCompareExamples(t *testing.T) {
+	files, err := filepath.Glob("../example/*.bf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backends := []difftest.Backend{difftest.Raw, difftest.Optimized}
+	if cc, err := exec.LookPath("cc"); err == nil && !testing.Short() {
+		backends = append(backends, difftest.C(cc))
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			src, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := testConfig()
+			c.MaxSteps = 10_000_000
+			r := difftest.Compare(context.Background(), src, nil, c, backends...)
+			if !r.OK() {
+				t.Error(r)
+			}
+		})
+	}
+}
+
+const instructions = "+-<>.,[]"
+
+// maxSeedSize keeps large examples out of the corpus, minimizing them takes
+// the fuzzer too long.
+const maxSeedSize = 2048
+
+// program turns fuzz data into a balanced program, closing the loops left
+// open at the end.
+func program(data []byte) []byte {
+	src := make([]byte, 0, len(data))
+	depth := 0
+	for _, b := range data {
+		in := instructions[int(b)%len(instructions)]
+		switch in {
+		case '[':
+			depth++
+		case ']':
+			if depth == 0 {
+				continue
+			}
+			depth--
+		}
+		src = append(src, in)
+	}
+	for ; depth > 0; depth-- {
+		src = append(src, ']')
+	}
+	return src
+}
+
+// seed returns the data program turns back into src.
+func seed(src []byte) []byte {
+	data := []byte{}
+	for _, b := range src {
+		if idx := strings.IndexByte(instructions, b); idx >= 0 {
+			data = append(data, byte(idx))
+		}
+	}
+	return data
+}
+
+func FuzzCompare(f *testing.F) {
+	files, err := filepath.Glob("../example/*.bf")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		if data := seed(src); len(data) <= maxSeedSize {
+			f.Add(data, []byte{})
+		}
+	}
+	f.Add(seed([]byte(">>+++[<]<[-]>>[>>]")), []byte("ab"))
+	f.Add(seed([]byte(",[.,]")), []byte("hello"))
+
+	f.Fuzz(func(t *testing.T, data []byte, input []byte) {
+		src := program(data)
+		r := difftest.Compare(context.Background(), src, input, testConfig())
+		if !r.OK() {
+			t.Errorf("%s\n%s", src, r)
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package formatter
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/rosylilly/brainfxxk/ast"
+)
+
+const (
+	indentWidth = 2
+	// inlineWidth is the longest loop kept on one line.
+	inlineWidth = 32
+)
+
+// Format writes the program with nested loops indented on their own lines.
+// Short loops without nested blocks stay on one line and comments are kept.
+func Format(w io.Writer, p *ast.Program) error {
+	f := &formatter{w: bufio.NewWriter(w)}
+	f.expressions(p.Expressions)
+	f.newline()
+	if p.Input != nil {
+		f.w.WriteByte('!')
+		f.w.Write(p.Input)
+	}
+	return f.w.Flush()
+}
+
+// Minify writes only the instructions of the program.
+func Minify(w io.Writer, p *ast.Program) error {
+	bw := bufio.NewWriter(w)
+	minify(bw, p.Expressions)
+	if p.Input != nil {
+		bw.WriteByte('!')
+		bw.Write(p.Input)
+	}
+	return bw.Flush()
+}
+
+func minify(w *bufio.Writer, exprs []ast.Expression) {
+	for _, expr := range exprs {
+		switch e := expr.(type) {
+		case *ast.Comment:
+		case *ast.WhileExpression:
+			w.WriteByte('[')
+			minify(w, e.Body)
+			w.WriteByte(']')
+		case *ast.ProcedureExpression:
+			w.WriteByte('(')
+			minify(w, e.Body)
+			w.WriteByte(')')
+		default:
+			w.Write(e.Bytes())
+		}
+	}
+}
+
+type formatter struct {
+	w      *bufio.Writer
+	indent int
+	line   []byte
+	// comment is set when the line ends with comment text.
+	comment bool
+}
+
+func (f *formatter) expressions(exprs []ast.Expression) {
+	for _, expr := range exprs {
+		switch e := expr.(type) {
+		case *ast.Comment:
+			f.comments(e)
+		case *ast.WhileExpression:
+			f.block(e.Body, '[', ']')
+		case *ast.ProcedureExpression:
+			f.block(e.Body, '(', ')')
+		default:
+			f.code(e.String())
+		}
+	}
+}
+
+func (f *formatter) block(body []ast.Expression, start, end byte) {
+	if inline, ok := inlineBlock(body, start, end); ok {
+		f.code(inline)
+		return
+	}
+
+	f.newline()
+	f.code(string(start))
+	f.newline()
+	f.indent++
+	f.expressions(body)
+	f.newline()
+	f.indent--
+	f.code(string(end))
+	f.newline()
+}
+
+func inlineBlock(body []ast.Expression, start, end byte) (string, bool) {
+	b := &strings.Builder{}
+	b.WriteByte(start)
+	for _, expr := range body {
+		switch expr.(type) {
+		case *ast.WhileExpression, *ast.ProcedureExpression:
+			return "", false
+		case *ast.Comment:
+			if strings.TrimSpace(expr.String()) != "" {
+				return "", false
+			}
+		default:
+			b.WriteString(expr.String())
+		}
+	}
+	b.WriteByte(end)
+	return b.String(), b.Len() <= inlineWidth
+}
+
+func (f *formatter) comments(c *ast.Comment) {
+	for n, text := range strings.Split(c.String(), "\n") {
+		if n > 0 {
+			f.newline()
+		}
+		if text = strings.Join(strings.Fields(text), " "); text == "" {
+			continue
+		}
+		if len(f.line) > 0 {
+			f.line = append(f.line, ' ')
+		}
+		f.line = append(f.line, text...)
+		f.comment = true
+	}
+}
+
+func (f *formatter) code(s string) {
+	if f.comment {
+		f.line = append(f.line, ' ')
+		f.comment = false
+	}
+	f.line = append(f.line, s...)
+}
+
+func (f *formatter) newline() {
+	if len(f.line) == 0 {
+		return
+	}
+	f.w.WriteString(strings.Repeat(" ", f.indent*indentWidth))
+	f.w.Write(f.line)
+	f.w.WriteByte('\n')
+	f.line = f.line[:0]
+	f.comment = false
+}
@@ -0,0 +1,95 @@
+package formatter_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rosylilly/brainfxxk/formatter"
+	"github.com/rosylilly/brainfxxk/lexer"
+	"github.com/rosylilly/brainfxxk/parser"
+)
+
+func TestFormat(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    "++[>+<-]>.",
+			expected: "++[>+<-]>.\n",
+		},
+		{
+			input:    "+[>++[>+++<-]<-]",
+			expected: "+\n[\n  >++[>+++<-]<-\n]\n",
+		},
+		{
+			input:    "  +++   add three\n\n[-]  clear\n",
+			expected: "+++ add three\n[-] clear\n",
+		},
+		{
+			input:    "+[ loop\n>[<]\n]",
+			expected: "+\n[\n  loop\n  >[<]\n]\n",
+		},
+		{
+			input:    ",.!abc",
+			expected: ",.\n!abc",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			p, err := parser.ParseWithConfig(strings.NewReader(tc.input), &lexer.Config{InputSeparator: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := &bytes.Buffer{}
+			if err := formatter.Format(w, p); err != nil {
+				t.Fatal(err)
+			}
+			if w.String() != tc.expected {
+				t.Errorf("got: %q, expected: %q", w.String(), tc.expected)
+			}
+
+			p, err = parser.ParseWithConfig(strings.NewReader(w.String()), &lexer.Config{InputSeparator: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			again := &bytes.Buffer{}
+			if err := formatter.Format(again, p); err != nil {
+				t.Fatal(err)
+			}
+			if again.String() != w.String() {
+				t.Errorf("formatting is not stable: %q became %q", w.String(), again.String())
+			}
+		})
+	}
+}
+
+func TestMinify(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{input: "+ + [ > + < - ] comment .", expected: "++[>+<-]."},
+		{input: "+(>+<)\n:", expected: "+(>+<):"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			p, err := parser.ParseWithConfig(strings.NewReader(tc.input), &lexer.Config{Procedures: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := &bytes.Buffer{}
+			if err := formatter.Minify(w, p); err != nil {
+				t.Fatal(err)
+			}
+			if w.String() != tc.expected {
+				t.Errorf("got: %q, expected: %q", w.String(), tc.expected)
+			}
+		})
+	}
+}
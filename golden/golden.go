@@ -0,0 +1,291 @@
+package golden
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rosylilly/brainfxxk/interpreter"
+)
+
+var (
+	ErrInvalidHeader = fmt.Errorf("invalid header")
+	ErrNoExpectation = fmt.Errorf("no expected output")
+)
+
+const (
+	// headerPrefix starts the header lines at the top of a program, like
+	//
+	//	;; input: 5\n
+	//	;; output: 1 2 3 4 5\n
+	//
+	// Values are unquoted like Go strings.
+	headerPrefix = ";;"
+
+	inputExt  = ".in"
+	outputExt = ".out"
+)
+
+// Case is a program with the input to give it and the output it must write.
+// Sidecar .in and .out files next to the program take precedence over its
+// header.
+type Case struct {
+	Path   string
+	Source []byte
+	Input  []byte
+	Output []byte
+	// HasOutput is false when neither a sidecar nor the header give the
+	// expected output.
+	HasOutput bool
+
+	header       []headerField
+	outputHeader bool
+}
+
+type headerField struct {
+	key   string
+	value string
+}
+
+type Config struct {
+	// Interpreter is the configuration programs run under, its Reader and
+	// Writer are replaced for each case.
+	Interpreter interpreter.Config
+	// Update writes the actual output as the expected one instead of
+	// comparing them.
+	Update bool
+}
+
+type Result struct {
+	Case   *Case
+	Output []byte
+	Err    error
+	// Diff describes how Output differs from the expected output, it is
+	// empty when they match.
+	Diff    string
+	Updated bool
+}
+
+func (r *Result) Passed() bool {
+	return r.Err == nil && r.Diff == ""
+}
+
+// Discover returns the cases for the *.bf files under the paths, in order.
+// Paths naming a file are used as they are.
+func Discover(paths ...string) ([]*Case, error) {
+	var files []string
+	for _, path := range paths {
+		err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if p == path && !d.IsDir() || !d.IsDir() && filepath.Ext(p) == ".bf" {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+
+	cases := make([]*Case, 0, len(files))
+	for _, file := range files {
+		c, err := Load(file)
+		if err != nil {
+			return nil, err
+		}
+		cases = append(cases, c)
+	}
+	return cases, nil
+}
+
+// Load reads the program at path with its header and sidecar files.
+func Load(path string) (*Case, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Case{Path: path}
+	if c.Source, c.header, err = parseHeader(src); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, f := range c.header {
+		switch f.key {
+		case "input":
+			c.Input = []byte(f.value)
+		case "output":
+			c.Output = []byte(f.value)
+			c.HasOutput = true
+			c.outputHeader = true
+		}
+	}
+
+	if input, err := os.ReadFile(sidecar(path, inputExt)); err == nil {
+		c.Input = input
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if output, err := os.ReadFile(sidecar(path, outputExt)); err == nil {
+		c.Output = output
+		c.HasOutput = true
+		c.outputHeader = false
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return c, nil
+}
+
+// parseHeader splits the header lines from the source. The lines are kept
+// empty so that positions in the source stay the same.
+func parseHeader(src []byte) ([]byte, []headerField, error) {
+	var fields []headerField
+	body := &bytes.Buffer{}
+	rest := src
+	for len(rest) > 0 {
+		line, next, found := bytes.Cut(rest, []byte("\n"))
+		text := strings.TrimSpace(string(line))
+		if !strings.HasPrefix(text, headerPrefix) {
+			break
+		}
+
+		key, value, ok := strings.Cut(strings.TrimPrefix(text, headerPrefix), ":")
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %s", ErrInvalidHeader, text)
+		}
+		unquoted, err := strconv.Unquote(`"` + strings.TrimSpace(value) + `"`)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s", ErrInvalidHeader, text)
+		}
+		fields = append(fields, headerField{key: strings.TrimSpace(key), value: unquoted})
+
+		if found {
+			body.WriteByte('\n')
+		}
+		rest = next
+	}
+	body.Write(rest)
+	return body.Bytes(), fields, nil
+}
+
+func formatHeader(fields []headerField) []byte {
+	b := &bytes.Buffer{}
+	for _, f := range fields {
+		quoted := strconv.Quote(f.value)
+		fmt.Fprintf(b, "%s %s: %s\n", headerPrefix, f.key, quoted[1:len(quoted)-1])
+	}
+	return b.Bytes()
+}
+
+func sidecar(path, ext string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ext
+}
+
+// Run runs the case and compares its output, or updates the expected output
+// under c.Update. Cases without expected output are only run to update them.
+func Run(ctx context.Context, tc *Case, c *Config) *Result {
+	r := &Result{Case: tc}
+	if !tc.HasOutput && !c.Update {
+		r.Err = ErrNoExpectation
+		return r
+	}
+
+	out := &bytes.Buffer{}
+	ic := c.Interpreter
+	ic.Reader = bytes.NewReader(tc.Input)
+	ic.Writer = out
+	ic.AstInfo = false
+
+	_, r.Err = interpreter.Run(ctx, bytes.NewReader(tc.Source), &ic)
+	r.Output = out.Bytes()
+	if r.Err != nil {
+		return r
+	}
+
+	if c.Update {
+		if !tc.HasOutput || !bytes.Equal(tc.Output, r.Output) {
+			r.Err = tc.update(r.Output)
+			r.Updated = r.Err == nil
+		}
+		return r
+	}
+	r.Diff = Diff(tc.Output, r.Output)
+	return r
+}
+
+// RunAll runs the cases in order and stops early only when ctx is done.
+func RunAll(ctx context.Context, cases []*Case, c *Config) []*Result {
+	results := make([]*Result, 0, len(cases))
+	for _, tc := range cases {
+		if ctx.Err() != nil {
+			break
+		}
+		results = append(results, Run(ctx, tc, c))
+	}
+	return results
+}
+
+// update writes output where the expected output came from, the sidecar
+// .out file unless the header held it.
+func (tc *Case) update(output []byte) error {
+	tc.Output = output
+	tc.HasOutput = true
+	if !tc.outputHeader {
+		return os.WriteFile(sidecar(tc.Path, outputExt), output, 0o644)
+	}
+
+	for idx := range tc.header {
+		if tc.header[idx].key == "output" {
+			tc.header[idx].value = string(output)
+		}
+	}
+	// the header lines are blank in Source.
+	body := tc.Source[min(len(tc.header), len(tc.Source)):]
+	src := append(formatHeader(tc.header), body...)
+
+	info, err := os.Stat(tc.Path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(tc.Path, src, info.Mode())
+}
+
+// Diff returns the lines that differ between expected and got, or an empty
+// string when they are equal.
+func Diff(expected, got []byte) string {
+	if bytes.Equal(expected, got) {
+		return ""
+	}
+
+	want := strings.SplitAfter(string(expected), "\n")
+	have := strings.SplitAfter(string(got), "\n")
+	b := &strings.Builder{}
+	for idx := 0; idx < max(len(want), len(have)); idx++ {
+		var w, h string
+		if idx < len(want) {
+			w = want[idx]
+		}
+		if idx < len(have) {
+			h = have[idx]
+		}
+		if w == h {
+			continue
+		}
+		fmt.Fprintf(b, "line %d:\n", idx+1)
+		if idx < len(want) {
+			fmt.Fprintf(b, "  - %q\n", w)
+		}
+		if idx < len(have) {
+			fmt.Fprintf(b, "  + %q\n", h)
+		}
+	}
+	return b.String()
+}
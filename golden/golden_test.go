@@ -0,0 +1,161 @@
+package golden_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rosylilly/brainfxxk/golden"
+	"github.com/rosylilly/brainfxxk/interpreter"
+)
+
+func testConfig(update bool) *golden.Config {
+	return &golden.Config{
+		Interpreter: interpreter.Config{MemorySize: 30000, MaxSteps: 1_000_000},
+		Update:      update,
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	cases, err := golden.Discover("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		path   string
+		input  string
+		output string
+	}{
+		{path: "testdata/cat.bf", input: "meow\n", output: "meow\n"},
+		{path: "testdata/hello-world.bf", input: "", output: "Hello, world!"},
+		{path: "testdata/reverse.bf", input: "ab", output: "ba"},
+	}
+	if len(cases) != len(expected) {
+		t.Fatalf("got: %d cases, expected: %d", len(cases), len(expected))
+	}
+	for idx, e := range expected {
+		c := cases[idx]
+		if c.Path != e.path || string(c.Input) != e.input || string(c.Output) != e.output || !c.HasOutput {
+			t.Errorf("got: %s %q %q, expected: %s %q %q", c.Path, c.Input, c.Output, e.path, e.input, e.output)
+		}
+	}
+
+	for _, r := range golden.RunAll(context.Background(), cases, testConfig(false)) {
+		if !r.Passed() {
+			t.Errorf("%s: %v\n%s", r.Case.Path, r.Err, r.Diff)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	testCases := []struct {
+		name     string
+		source   string
+		diff     string
+		expected error
+	}{
+		{
+			name:   "pass",
+			source: ";; output: \\x01\\x02\n+.+.",
+		},
+		{
+			name:   "mismatch",
+			source: ";; output: ab\\ncd\n" + "++++++++[>++++++++++++<-]>+.+.++++++++++.",
+			diff:   "line 1:\n  - \"ab\\n\"\n  + \"abl\"\nline 2:\n  - \"cd\"\n",
+		},
+		{
+			name:     "no expectation",
+			source:   "+.",
+			expected: golden.ErrNoExpectation,
+		},
+		{
+			name:     "step limit",
+			source:   ";; output: \n+[]",
+			expected: interpreter.ErrStepLimitExceeded,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "case.bf")
+			if err := os.WriteFile(path, []byte(tc.source), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			c, err := golden.Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := golden.Run(context.Background(), c, testConfig(false))
+			if !errors.Is(r.Err, tc.expected) {
+				t.Errorf("got: %v, expected: %v", r.Err, tc.expected)
+			}
+			if r.Diff != tc.diff {
+				t.Errorf("got: %q, expected: %q", r.Diff, tc.diff)
+			}
+		})
+	}
+}
+
+func TestInvalidHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "case.bf")
+	if err := os.WriteFile(path, []byte(";; output\n+."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := golden.Load(path); !errors.Is(err, golden.ErrInvalidHeader) {
+		t.Errorf("got: %v, expected: %v", err, golden.ErrInvalidHeader)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"sidecar.bf":  "++++++++[>++++++++<-]>+.",
+		"sidecar.out": "B",
+		"header.bf":   ";; input: x\n;; output: old\n,.",
+		"missing.bf":  "++++++++[>++++++++<-]>++.",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases, err := golden.Discover(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range golden.RunAll(context.Background(), cases, testConfig(true)) {
+		if r.Err != nil || !r.Updated {
+			t.Errorf("%s: not updated: %v", r.Case.Path, r.Err)
+		}
+	}
+
+	expected := map[string]string{
+		"sidecar.out": "A",
+		"header.bf":   ";; input: x\n;; output: x\n,.",
+		"missing.out": "B",
+	}
+	for name, content := range expected {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("%s: got: %q, expected: %q", name, got, content)
+		}
+	}
+
+	cases, err = golden.Discover(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range golden.RunAll(context.Background(), cases, testConfig(false)) {
+		if !r.Passed() {
+			t.Errorf("%s: %v\n%s", r.Case.Path, r.Err, r.Diff)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+;; input: ab
+;; output: ba
+,>,.<.
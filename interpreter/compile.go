@@ -0,0 +1,132 @@
+package interpreter
+
+import (
+	"github.com/rosylilly/brainfxxk/ast"
+)
+
+type instruction struct {
+	expr ast.Expression
+	// end marks the closing bracket of a WhileExpression or
+	// ProcedureExpression.
+	end   bool
+	jump  int
+	depth int
+	// size is the number of source instructions folded into expr.
+	size int
+	// parent is the start of the enclosing block, -1 at the top level.
+	parent int
+	// cell is set when the instruction uses the cell under the pointer.
+	cell bool
+}
+
+// compile flattens the tree into instructions run by a pc loop, so that the
+// debugger can stop between any two of them and resume inside a loop. They
+// are appended to code.
+func compile(code []instruction, exprs []ast.Expression) []instruction {
+	return compileExpressions(code, exprs, 0, -1)
+}
+
+func compileExpressions(code []instruction, exprs []ast.Expression, depth int, parent int) []instruction {
+	for _, expr := range exprs {
+		switch e := expr.(type) {
+		case *ast.Comment:
+			continue
+		case *ast.WhileExpression:
+			start := len(code)
+			code = append(code, instruction{expr: e, depth: depth, size: 1, parent: parent, cell: true})
+			code = compileExpressions(code, e.Body, depth+1, start)
+			code = append(code, instruction{expr: e, end: true, jump: start, depth: depth, size: 1, parent: parent, cell: true})
+			code[start].jump = len(code) - 1
+		case *ast.ProcedureExpression:
+			start := len(code)
+			code = append(code, instruction{expr: e, depth: depth, size: 1, parent: parent, cell: true})
+			code = compileExpressions(code, e.Body, depth+1, start)
+			code = append(code, instruction{expr: e, end: true, jump: start, depth: depth, size: 1, parent: parent})
+			code[start].jump = len(code) - 1
+		default:
+			code = append(code, instruction{expr: e, depth: depth, size: expressionSize(e), parent: parent, cell: usesCell(e)})
+		}
+	}
+	return code
+}
+
+// positions returns the source positions the instruction was built from.
+func (in *instruction) positions() []int {
+	switch e := in.expr.(type) {
+	case *ast.WhileExpression:
+		if in.end {
+			return []int{e.EndPosition}
+		}
+		return []int{e.StartPosition}
+	case *ast.ProcedureExpression:
+		if in.end {
+			return []int{e.EndPosition}
+		}
+		return []int{e.StartPosition}
+	case *ast.ValueResetExpression:
+		return positionRange(e.StartPos(), e.EndPos())
+	case *ast.ZeroSearchExpression:
+		return positionRange(e.StartPosition, e.EndPosition)
+	case *ast.PointerMoveExpression:
+		return expressionPositions(e.Expressions)
+	case *ast.ValueChangeExpression:
+		return expressionPositions(e.Expressions)
+	case *ast.MultiplePointerIncrementExpression:
+		return expressionPositions(e.Expressions)
+	case *ast.MultiplePointerDecrementExpression:
+		return expressionPositions(e.Expressions)
+	case *ast.MultipleValueIncrementExpression:
+		return expressionPositions(e.Expressions)
+	case *ast.MultipleValueDecrementExpression:
+		return expressionPositions(e.Expressions)
+	}
+	return []int{in.expr.StartPos()}
+}
+
+func (in *instruction) pos() int {
+	return in.positions()[0]
+}
+
+func positionRange(start, end int) []int {
+	positions := []int{}
+	for pos := start; pos <= end; pos++ {
+		positions = append(positions, pos)
+	}
+	return positions
+}
+
+func expressionPositions(exprs []ast.Expression) []int {
+	positions := make([]int, 0, len(exprs))
+	for _, expr := range exprs {
+		positions = append(positions, expr.StartPos())
+	}
+	return positions
+}
+
+func usesCell(expr ast.Expression) bool {
+	switch expr.(type) {
+	case *ast.PointerIncrementExpression, *ast.PointerDecrementExpression,
+		*ast.MultiplePointerIncrementExpression, *ast.MultiplePointerDecrementExpression,
+		*ast.PointerMoveExpression:
+		return false
+	}
+	return true
+}
+
+func expressionSize(expr ast.Expression) int {
+	switch e := expr.(type) {
+	case *ast.PointerMoveExpression:
+		return len(e.Expressions)
+	case *ast.ValueChangeExpression:
+		return len(e.Expressions)
+	case *ast.MultiplePointerIncrementExpression:
+		return len(e.Expressions)
+	case *ast.MultiplePointerDecrementExpression:
+		return len(e.Expressions)
+	case *ast.MultipleValueIncrementExpression:
+		return len(e.Expressions)
+	case *ast.MultipleValueDecrementExpression:
+		return len(e.Expressions)
+	}
+	return 1
+}
@@ -2,6 +2,19 @@ package interpreter
 
 import (
 	"io"
+
+	"github.com/rosylilly/brainfxxk/lexer"
+	"github.com/rosylilly/brainfxxk/optimizer"
+)
+
+type ForkMemory int
+
+const (
+	// SharedForkMemory lets forked threads share one tape as Brainfork
+	// specifies.
+	SharedForkMemory ForkMemory = iota
+	// CopiedForkMemory gives each forked thread a copy of the tape.
+	CopiedForkMemory
 )
 
 type Config struct {
@@ -11,4 +24,46 @@ type Config struct {
 	RaiseErrorOnOverflow bool
 	RaiseErrorOnEOF      bool
 	AstInfo              bool
+	// Unoptimized runs the program as parsed, one expression per source
+	// instruction.
+	Unoptimized bool
+	// Optimizer chooses the optimization passes, every pass runs when it is
+	// nil.
+	Optimizer *optimizer.Config
+
+	// OutputBufferSize buffers this many bytes of output before writing
+	// them to Writer, FlushPolicy tells when to write them earlier. Output
+	// is written byte by byte when it is zero.
+	OutputBufferSize int
+	FlushPolicy      FlushPolicy
+
+	Syntax lexer.Config
+	// DebugWriter receives the tape dumps of '#' instructions. Dumps are
+	// discarded when it is nil.
+	DebugWriter io.Writer
+	// MaxCallDepth limits nested procedure calls, defaultMaxCallDepth when
+	// zero.
+	MaxCallDepth int
+	// MaxSteps stops execution with ErrStepLimitExceeded once more source
+	// instructions would run. Zero means no limit.
+	MaxSteps int
+	// MaxOutputBytes and MaxInputBytes limit the bytes written and read,
+	// zero means no limit.
+	MaxOutputBytes int
+	MaxInputBytes  int
+	// MaxMemorySize lets the tape grow past MemorySize up to this many
+	// cells. The tape has a fixed size when it is not above MemorySize.
+	MaxMemorySize int
+
+	// Profiler collects execution counts per expression when set.
+	Profiler *Profiler
+	// Trace logs every executed instruction when set.
+	Trace *Tracer
+	// History records execution for stepping backwards when set.
+	History *History
+
+	ForkMemory ForkMemory
+	// SerializeForkOutput buffers the output of each thread and writes it
+	// after all threads finished, parents before the threads they forked.
+	SerializeForkOutput bool
 }
@@ -0,0 +1,246 @@
+package interpreter
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/rosylilly/brainfxxk/ast"
+)
+
+var (
+	ErrNoInstruction = fmt.Errorf("no instruction")
+	ErrNoSourceMap   = fmt.Errorf("no source map")
+)
+
+type StopReason int
+
+const (
+	StopStep StopReason = iota
+	StopBreakpoint
+	StopWatch
+	StopFinished
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case StopStep:
+		return "step"
+	case StopBreakpoint:
+		return "breakpoint"
+	case StopWatch:
+		return "watch"
+	case StopFinished:
+		return "finished"
+	}
+	return "unknown"
+}
+
+type Stop struct {
+	Reason StopReason
+
+	// Cell, Old and New are set when Reason is StopWatch.
+	Cell int
+	Old  byte
+	New  byte
+}
+
+// Current returns the expression that will be executed next, or nil when the
+// program has finished.
+func (i *Interpreter) Current() (ast.Expression, error) {
+	if err := i.prepare(); err != nil {
+		return nil, err
+	}
+	if i.Finished() {
+		return nil, nil
+	}
+	return i.code[i.pc].expr, nil
+}
+
+// CurrentPos returns the source position of the instruction that will be
+// executed next.
+func (i *Interpreter) CurrentPos() (int, error) {
+	if err := i.prepare(); err != nil {
+		return 0, err
+	}
+	if i.Finished() {
+		return 0, ErrNoInstruction
+	}
+	return i.code[i.pc].pos(), nil
+}
+
+func (i *Interpreter) Step(ctx context.Context) (*Stop, error) {
+	if err := i.prepare(); err != nil {
+		return nil, err
+	}
+	if i.Finished() {
+		return &Stop{Reason: StopFinished}, nil
+	}
+
+	if err := i.exec(ctx); err != nil {
+		return nil, err
+	}
+	if stop := i.checkWatches(); stop != nil {
+		return stop, nil
+	}
+	if i.Finished() {
+		return &Stop{Reason: StopFinished}, nil
+	}
+	return &Stop{Reason: StopStep}, nil
+}
+
+// StepOver runs the loop starting at, or the procedure called by, the current
+// instruction to completion. On any other instruction it behaves like Step.
+func (i *Interpreter) StepOver(ctx context.Context) (*Stop, error) {
+	if err := i.prepare(); err != nil {
+		return nil, err
+	}
+	if i.Finished() {
+		return &Stop{Reason: StopFinished}, nil
+	}
+
+	in := &i.code[i.pc]
+	if in.end {
+		return i.Step(ctx)
+	}
+
+	switch in.expr.(type) {
+	case *ast.WhileExpression:
+		return i.runUntil(ctx, func() bool {
+			return i.pc == in.jump+1
+		})
+	case *ast.CallExpression:
+		pc := i.pc
+		depth := len(i.calls)
+		return i.runUntil(ctx, func() bool {
+			return i.pc == pc+1 && len(i.calls) == depth
+		})
+	}
+	return i.Step(ctx)
+}
+
+func (i *Interpreter) Continue(ctx context.Context) (*Stop, error) {
+	if err := i.prepare(); err != nil {
+		return nil, err
+	}
+
+	return i.runUntil(ctx, func() bool {
+		return false
+	})
+}
+
+func (i *Interpreter) runUntil(ctx context.Context, done func() bool) (*Stop, error) {
+	for first := true; !i.Finished(); first = false {
+		if !first && i.breakpoints[i.pc] {
+			return &Stop{Reason: StopBreakpoint}, nil
+		}
+
+		if err := i.exec(ctx); err != nil {
+			return nil, err
+		}
+		if stop := i.checkWatches(); stop != nil {
+			return stop, nil
+		}
+		if done() {
+			return &Stop{Reason: StopStep}, nil
+		}
+	}
+	return &Stop{Reason: StopFinished}, nil
+}
+
+// SetBreakpoint stops execution before the instruction built from the source
+// position pos. Optimized instructions cover every position they were folded
+// from.
+func (i *Interpreter) SetBreakpoint(pos int) error {
+	if err := i.prepare(); err != nil {
+		return err
+	}
+
+	found := false
+	for idx := range i.code {
+		for _, p := range i.code[idx].positions() {
+			if p == pos {
+				i.breakpoints[idx] = true
+				found = true
+			}
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: at %d", ErrNoInstruction, pos)
+	}
+	return nil
+}
+
+// SetLineBreakpoint stops execution before the first instruction on the
+// 1-based line.
+func (i *Interpreter) SetLineBreakpoint(line int) error {
+	if err := i.prepare(); err != nil {
+		return err
+	}
+	if i.Source == nil {
+		return ErrNoSourceMap
+	}
+
+	start, end, ok := i.Source.LineRange(line)
+	if ok {
+		for idx := range i.code {
+			for _, p := range i.code[idx].positions() {
+				if p >= start && p < end {
+					i.breakpoints[idx] = true
+					return nil
+				}
+			}
+		}
+	}
+	return fmt.Errorf("%w: on line %d", ErrNoInstruction, line)
+}
+
+func (i *Interpreter) ClearBreakpoints() {
+	i.breakpoints = map[int]bool{}
+}
+
+func (i *Interpreter) Watch(cell int) error {
+	if cell < 0 || cell >= len(i.Memory) {
+		return fmt.Errorf("%w: cell %d is out of memory", ErrMemoryOverflow, cell)
+	}
+	i.watches[cell] = i.Memory[cell]
+	return nil
+}
+
+func (i *Interpreter) Unwatch(cell int) {
+	delete(i.watches, cell)
+}
+
+func (i *Interpreter) checkWatches() *Stop {
+	var stop *Stop
+	for cell, old := range i.watches {
+		if i.Memory[cell] == old {
+			continue
+		}
+		i.watches[cell] = i.Memory[cell]
+		if stop == nil || cell < stop.Cell {
+			stop = &Stop{Reason: StopWatch, Cell: cell, Old: old, New: i.Memory[cell]}
+		}
+	}
+	return stop
+}
+
+// WriteTape writes the cells within radius of the pointer.
+func (i *Interpreter) WriteTape(w io.Writer, radius int) error {
+	start := max(i.Pointer-radius, 0)
+	end := min(i.Pointer+radius, len(i.Memory)-1)
+
+	if _, err := fmt.Fprintf(w, "pointer: %d\n", i.Pointer); err != nil {
+		return err
+	}
+	for cell := start; cell <= end; cell++ {
+		mark := " "
+		if cell == i.Pointer {
+			mark = ">"
+		}
+		if _, err := fmt.Fprintf(w, "%s %5d: %3d\n", mark, cell, i.Memory[cell]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package interpreter_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rosylilly/brainfxxk/ast"
+	"github.com/rosylilly/brainfxxk/interpreter"
+	"github.com/rosylilly/brainfxxk/parser"
+)
+
+func newDebugInterpreter(t *testing.T, source string) (*interpreter.Interpreter, *bytes.Buffer) {
+	t.Helper()
+
+	p, err := parser.Parse(strings.NewReader(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := &bytes.Buffer{}
+	ip := interpreter.NewInterpreter(p, &interpreter.Config{
+		Writer:     w,
+		Reader:     strings.NewReader(""),
+		MemorySize: 16,
+	})
+	ip.Source = ast.NewSourceMap([]byte(source))
+	return ip, w
+}
+
+func TestInterpreterStep(t *testing.T) {
+	ctx := context.Background()
+	ip, _ := newDebugInterpreter(t, "++>+<[-]")
+
+	expected := []struct {
+		pos     int
+		pointer int
+		value   byte
+	}{
+		{pos: 2, pointer: 0, value: 2},
+		{pos: 3, pointer: 1, value: 0},
+		{pos: 4, pointer: 1, value: 1},
+		{pos: 5, pointer: 0, value: 2},
+	}
+
+	for _, e := range expected {
+		stop, err := ip.Step(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stop.Reason != interpreter.StopStep {
+			t.Fatalf("reason: got: %v, expected: %v", stop.Reason, interpreter.StopStep)
+		}
+
+		pos, err := ip.CurrentPos()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pos != e.pos {
+			t.Errorf("pos: got: %v, expected: %v", pos, e.pos)
+		}
+		if ip.Pointer != e.pointer {
+			t.Errorf("pointer: got: %v, expected: %v", ip.Pointer, e.pointer)
+		}
+		if ip.Memory[ip.Pointer] != e.value {
+			t.Errorf("value: got: %v, expected: %v", ip.Memory[ip.Pointer], e.value)
+		}
+	}
+
+	stop, err := ip.Step(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stop.Reason != interpreter.StopFinished {
+		t.Errorf("reason: got: %v, expected: %v", stop.Reason, interpreter.StopFinished)
+	}
+}
+
+func TestInterpreterBreakpoint(t *testing.T) {
+	ctx := context.Background()
+	ip, w := newDebugInterpreter(t, "+++[>++<-]>.\n>+.")
+
+	// position 6 is folded into the "++" of the loop body.
+	if err := ip.SetBreakpoint(6); err != nil {
+		t.Fatal(err)
+	}
+
+	for n := 0; n < 3; n++ {
+		stop, err := ip.Continue(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stop.Reason != interpreter.StopBreakpoint {
+			t.Fatalf("reason: got: %v, expected: %v", stop.Reason, interpreter.StopBreakpoint)
+		}
+		if ip.Memory[0] != byte(3-n) {
+			t.Errorf("memory: got: %v, expected: %v", ip.Memory[0], 3-n)
+		}
+	}
+
+	ip.ClearBreakpoints()
+	if err := ip.SetLineBreakpoint(2); err != nil {
+		t.Fatal(err)
+	}
+
+	stop, err := ip.Continue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stop.Reason != interpreter.StopBreakpoint {
+		t.Fatalf("reason: got: %v, expected: %v", stop.Reason, interpreter.StopBreakpoint)
+	}
+	if pos, _ := ip.CurrentPos(); pos != 13 {
+		t.Errorf("pos: got: %v, expected: %v", pos, 13)
+	}
+	if w.String() != "\x06" {
+		t.Errorf("output: got: %q, expected: %q", w.String(), "\x06")
+	}
+
+	stop, err = ip.Continue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stop.Reason != interpreter.StopFinished {
+		t.Errorf("reason: got: %v, expected: %v", stop.Reason, interpreter.StopFinished)
+	}
+
+	if err := ip.SetBreakpoint(100); !errors.Is(err, interpreter.ErrNoInstruction) {
+		t.Errorf("got: %v, expected: %v", err, interpreter.ErrNoInstruction)
+	}
+}
+
+func TestInterpreterStepOverAndWatch(t *testing.T) {
+	ctx := context.Background()
+	ip, _ := newDebugInterpreter(t, "+++[>++<-]>>+")
+
+	if _, err := ip.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	stop, err := ip.StepOver(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stop.Reason != interpreter.StopStep {
+		t.Fatalf("reason: got: %v, expected: %v", stop.Reason, interpreter.StopStep)
+	}
+	if ip.Memory[0] != 0 || ip.Memory[1] != 6 {
+		t.Errorf("memory: got: %v", ip.Memory[:2])
+	}
+	if pos, _ := ip.CurrentPos(); pos != 10 {
+		t.Errorf("pos: got: %v, expected: %v", pos, 10)
+	}
+
+	if err := ip.Watch(2); err != nil {
+		t.Fatal(err)
+	}
+	stop, err = ip.Continue(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stop.Reason != interpreter.StopWatch || stop.Cell != 2 || stop.Old != 0 || stop.New != 1 {
+		t.Errorf("got: %+v", stop)
+	}
+}
+
+func TestInterpreterWriteTape(t *testing.T) {
+	ctx := context.Background()
+	ip, _ := newDebugInterpreter(t, "+>++>+++<")
+
+	if _, err := ip.Continue(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	w := &bytes.Buffer{}
+	if err := ip.WriteTape(w, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "pointer: 1\n      0:   1\n>     1:   2\n      2:   3\n"
+	if w.String() != expected {
+		t.Errorf("got: %q, expected: %q", w.String(), expected)
+	}
+}
@@ -0,0 +1,206 @@
+package interpreter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+)
+
+var (
+	ErrForkNotSupported = fmt.Errorf("fork is not supported")
+)
+
+type threads struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// memory serializes steps of all threads when the tape is shared.
+	memory *sync.Mutex
+
+	mu         sync.Mutex
+	count      int
+	maxPointer int
+	err        error
+}
+
+func (i *Interpreter) runThreads(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	t := &threads{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	if i.Config.ForkMemory == SharedForkMemory {
+		t.memory = &sync.Mutex{}
+		// threads share the backing array, so the tape can't grow later.
+		if err := i.growMemory(i.Config.MaxMemorySize - 1); err != nil {
+			return 0, err
+		}
+	}
+
+	// threads write unbuffered, a buffer would have to be shared or
+	// flushed in order.
+	if err := i.Flush(); err != nil {
+		return 0, err
+	}
+	i.buffer = nil
+	i.writer = i.Config.Writer
+
+	i.threads = t
+	i.usage.mu = &sync.Mutex{}
+	i.reader = &lockedReader{r: i.reader}
+	if i.Config.SerializeForkOutput {
+		i.output = &bytes.Buffer{}
+		i.writer = i.output
+	} else {
+		i.writer = &lockedWriter{w: i.writer}
+	}
+
+	t.start(i)
+	t.wg.Wait()
+	i.maxPointer = t.maxPointer
+
+	if i.Config.SerializeForkOutput {
+		if err := i.flushThreadOutput(i.Config.Writer); err != nil && t.err == nil {
+			t.err = err
+		}
+	}
+	return t.count, t.err
+}
+
+func (t *threads) start(i *Interpreter) {
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+
+		count, err := i.runThread(t.ctx)
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.count += count
+		t.maxPointer = max(t.maxPointer, i.maxPointer)
+		if err != nil && t.err == nil {
+			t.err = err
+			t.cancel()
+		}
+	}()
+}
+
+func (i *Interpreter) runThread(ctx context.Context) (int, error) {
+	for !i.Finished() {
+		if i.threads.memory != nil {
+			i.threads.memory.Lock()
+		}
+		err := i.exec(ctx)
+		if i.threads.memory != nil {
+			i.threads.memory.Unlock()
+		}
+		if err != nil {
+			return i.committed, err
+		}
+	}
+	return i.count, nil
+}
+
+// unlocked runs f without holding the shared tape, so that a thread waiting
+// for input doesn't stall the others. lockedReader still takes turns.
+func (i *Interpreter) unlocked(f func() error) error {
+	if i.threads == nil || i.threads.memory == nil {
+		return f()
+	}
+	i.threads.memory.Unlock()
+	defer i.threads.memory.Lock()
+	return f()
+}
+
+// fork starts a thread continuing after the current instruction. Following
+// Brainfork, the parent's cell becomes 0 and the child's pointer moves right
+// onto a cell set to 1.
+func (i *Interpreter) fork() error {
+	if i.threads == nil {
+		return fmt.Errorf("%w: while stepping", ErrForkNotSupported)
+	}
+	if i.Pointer+1 >= len(i.Memory) {
+		return fmt.Errorf("%w: %d to pointer overflow on fork", ErrMemoryOverflow, i.Pointer)
+	}
+
+	child := &Interpreter{
+		Program: i.Program,
+		Config:  i.Config,
+		Memory:  i.Memory,
+		Pointer: i.Pointer + 1,
+		Source:  i.Source,
+
+		maxPointer: max(i.maxPointer, i.Pointer+1),
+
+		reader: i.reader,
+		writer: i.writer,
+
+		code:   i.code,
+		pc:     i.pc + 1,
+		loaded: true,
+
+		usage: i.usage,
+
+		procedures: maps.Clone(i.procedures),
+		calls:      slices.Clone(i.calls),
+
+		breakpoints: map[int]bool{},
+		watches:     map[int]byte{},
+
+		threads: i.threads,
+	}
+	if i.Config.ForkMemory == CopiedForkMemory {
+		child.Memory = slices.Clone(i.Memory)
+	}
+	if i.output != nil {
+		child.output = &bytes.Buffer{}
+		child.writer = child.output
+	}
+
+	i.Memory[i.Pointer] = 0
+	child.Memory[child.Pointer] = 1
+	i.children = append(i.children, child)
+	i.threads.start(child)
+	return nil
+}
+
+func (i *Interpreter) flushThreadOutput(w io.Writer) error {
+	if _, err := w.Write(i.output.Bytes()); err != nil {
+		return err
+	}
+	for _, child := range i.children {
+		if err := child.flushThreadOutput(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (lw *lockedWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	return lw.w.Write(p)
+}
+
+type lockedReader struct {
+	mu sync.Mutex
+	r  io.Reader
+}
+
+func (lr *lockedReader) Read(p []byte) (int, error) {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	return lr.r.Read(p)
+}
@@ -0,0 +1,204 @@
+package interpreter_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rosylilly/brainfxxk/interpreter"
+	"github.com/rosylilly/brainfxxk/lexer"
+	"github.com/rosylilly/brainfxxk/parser"
+)
+
+func TestInterpreterFork(t *testing.T) {
+	testCases := []struct {
+		source   string
+		expected string
+		count    int
+	}{
+		{
+			source:   "Y.",
+			expected: "\x00\x01",
+			count:    3,
+		},
+		{
+			// parent, its first child and grandchild, then its second child.
+			source:   "YY.",
+			expected: "\x00\x00\x01\x01",
+			count:    7,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			c := &interpreter.Config{
+				Writer:              w,
+				Reader:              strings.NewReader(""),
+				MemorySize:          16,
+				Syntax:              lexer.Config{Fork: true},
+				ForkMemory:          interpreter.CopiedForkMemory,
+				SerializeForkOutput: true,
+			}
+
+			count, err := interpreter.Run(context.Background(), strings.NewReader(tc.source), c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w.String() != tc.expected {
+				t.Errorf("output: got: %q, expected: %q", w.String(), tc.expected)
+			}
+			if count != tc.count {
+				t.Errorf("count: got: %v, expected: %v", count, tc.count)
+			}
+		})
+	}
+}
+
+func TestInterpreterForkOutputBuffer(t *testing.T) {
+	for _, serialize := range []bool{false, true} {
+		w := &bytes.Buffer{}
+		c := &interpreter.Config{
+			Writer:              w,
+			Reader:              strings.NewReader(""),
+			MemorySize:          16,
+			OutputBufferSize:    16,
+			Syntax:              lexer.Config{Fork: true},
+			ForkMemory:          interpreter.CopiedForkMemory,
+			SerializeForkOutput: serialize,
+		}
+
+		// the buffered byte before the fork comes first, threads write
+		// unbuffered after it.
+		if _, err := interpreter.Run(context.Background(), strings.NewReader("++.Y."), c); err != nil {
+			t.Fatal(err)
+		}
+		got := w.String()
+		if serialize && got != "\x02\x00\x01" {
+			t.Errorf("serialized output: got: %q, expected: %q", got, "\x02\x00\x01")
+		}
+		if !serialize && (len(got) != 3 || got[0] != '\x02') {
+			t.Errorf("shared output: got: %q", got)
+		}
+	}
+}
+
+func TestInterpreterForkMemory(t *testing.T) {
+	testCases := []struct {
+		memory   interpreter.ForkMemory
+		expected byte
+	}{
+		{memory: interpreter.SharedForkMemory, expected: 3},
+		{memory: interpreter.CopiedForkMemory, expected: 0},
+	}
+
+	for _, tc := range testCases {
+		// only the child enters the loop and writes to the third cell.
+		p, err := parser.ParseWithConfig(strings.NewReader("Y[>+++<-]"), &lexer.Config{Fork: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ip := interpreter.NewInterpreter(p, &interpreter.Config{
+			Writer:     &bytes.Buffer{},
+			Reader:     strings.NewReader(""),
+			MemorySize: 4,
+			Syntax:     lexer.Config{Fork: true},
+			ForkMemory: tc.memory,
+		})
+		if _, err := ip.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if ip.Memory[2] != tc.expected {
+			t.Errorf("memory: got: %v, expected: %v", ip.Memory[2], tc.expected)
+		}
+	}
+}
+
+func TestInterpreterForkErrors(t *testing.T) {
+	testCases := []struct {
+		source     string
+		memorySize int
+		expected   error
+	}{
+		{
+			// the child spins forever while the parent finishes.
+			source:     "Y[]",
+			memorySize: 4,
+			expected:   context.DeadlineExceeded,
+		},
+		{
+			source:     ">Y",
+			memorySize: 2,
+			expected:   interpreter.ErrMemoryOverflow,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			c := &interpreter.Config{
+				Writer:     &bytes.Buffer{},
+				Reader:     strings.NewReader(""),
+				MemorySize: tc.memorySize,
+				Syntax:     lexer.Config{Fork: true},
+			}
+
+			_, err := interpreter.Run(ctx, strings.NewReader(tc.source), c)
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("got: %v, expected: %v", err, tc.expected)
+			}
+		})
+	}
+}
+
+// chanWriter sends every write to a channel.
+type chanWriter chan []byte
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	w <- bytes.Clone(p)
+	return len(p), nil
+}
+
+func TestInterpreterForkInput(t *testing.T) {
+	// the parent waits for input right away, while the child writes '2'
+	// before it reads too.
+	p, err := parser.ParseWithConfig(strings.NewReader("Y[>+++++++[<+++++++>-]<.[-]],"), &lexer.Config{Fork: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, w := io.Pipe()
+	output := make(chanWriter, 1)
+	ip := interpreter.NewInterpreter(p, &interpreter.Config{
+		Writer:     output,
+		Reader:     r,
+		MemorySize: 4,
+		Syntax:     lexer.Config{Fork: true},
+		ForkMemory: interpreter.SharedForkMemory,
+	})
+	done := make(chan error, 1)
+	go func() {
+		_, err := ip.Run(context.Background())
+		done <- err
+	}()
+
+	select {
+	case b := <-output:
+		if string(b) != "2" {
+			t.Errorf("output: got: %q, expected: %q", b, "2")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the thread waiting for input stalled the other")
+	}
+	w.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
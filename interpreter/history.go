@@ -0,0 +1,336 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/rosylilly/brainfxxk/ast"
+)
+
+const (
+	defaultCheckpointInterval = 4096
+	defaultMaxCheckpoints     = 256
+)
+
+var (
+	ErrNoHistory        = fmt.Errorf("no history")
+	ErrHistoryExhausted = fmt.Errorf("history exhausted")
+)
+
+// History records executed instructions so that a debugger can go back in
+// time. Every CheckpointInterval instructions it saves the whole machine and
+// starts a new undo log, so going back past the log restores a checkpoint and
+// replays forward. The oldest checkpoints are dropped beyond MaxCheckpoints,
+// which bounds how far back the history reaches.
+type History struct {
+	CheckpointInterval int
+	MaxCheckpoints     int
+
+	executed    int
+	checkpoints []checkpoint
+	log         []undo
+
+	// input holds every byte read so that replays read the same input, and
+	// written is the output already written, which replays don't repeat.
+	input   []byte
+	written int64
+}
+
+type checkpoint struct {
+	executed int
+	state    *Snapshot
+}
+
+// undo holds what an instruction changed.
+type undo struct {
+	pc         int
+	pointer    int
+	maxPointer int
+	old        byte
+	wrote      bool
+	count      int
+	committed  int
+	steps      int64
+	input      int64
+	output     int64
+
+	calls   int
+	callTop int
+
+	procedure    byte
+	procedureOld int
+	procedureSet bool
+	defined      bool
+}
+
+func NewHistory() *History {
+	return &History{
+		CheckpointInterval: defaultCheckpointInterval,
+		MaxCheckpoints:     defaultMaxCheckpoints,
+	}
+}
+
+func (h *History) reset() {
+	h.executed = 0
+	h.checkpoints = nil
+	h.log = nil
+	h.input = nil
+	h.written = 0
+}
+
+func (h *History) checkpointInterval() int {
+	if h.CheckpointInterval > 0 {
+		return h.CheckpointInterval
+	}
+	return defaultCheckpointInterval
+}
+
+func (h *History) record(i *Interpreter) {
+	if h.executed%h.checkpointInterval() == 0 &&
+		(len(h.checkpoints) == 0 || h.checkpoints[len(h.checkpoints)-1].executed < h.executed) {
+		h.checkpoints = append(h.checkpoints, checkpoint{executed: h.executed, state: i.snapshot()})
+		if h.MaxCheckpoints > 0 && len(h.checkpoints) > h.MaxCheckpoints {
+			h.checkpoints = h.checkpoints[1:]
+		}
+		h.log = h.log[:0]
+	}
+
+	in := &i.code[i.pc]
+	u := undo{
+		pc:         i.pc,
+		pointer:    i.Pointer,
+		maxPointer: i.maxPointer,
+		count:      i.count,
+		committed:  i.committed,
+		steps:      i.usage.steps,
+		input:      i.usage.input,
+		output:     i.usage.output,
+		calls:      len(i.calls),
+	}
+	if i.Pointer >= 0 && i.Pointer < len(i.Memory) {
+		u.old = i.Memory[i.Pointer]
+		u.wrote = writesCell(in)
+	}
+	if len(i.calls) > 0 {
+		u.callTop = i.calls[len(i.calls)-1]
+	}
+	if _, ok := in.expr.(*ast.ProcedureExpression); ok && !in.end {
+		u.defined = true
+		u.procedure = u.old
+		u.procedureOld, u.procedureSet = i.procedures[u.old]
+	}
+	h.log = append(h.log, u)
+}
+
+func (h *History) undo(i *Interpreter) undo {
+	u := h.log[len(h.log)-1]
+	h.log = h.log[:len(h.log)-1]
+
+	i.pc = u.pc
+	i.Pointer = u.pointer
+	i.maxPointer = u.maxPointer
+	if u.wrote {
+		i.Memory[u.pointer] = u.old
+	}
+	i.count = u.count
+	i.committed = u.committed
+	i.usage.steps = u.steps
+	i.usage.input = u.input
+	i.usage.output = u.output
+
+	switch {
+	case len(i.calls) > u.calls:
+		i.calls = i.calls[:u.calls]
+	case len(i.calls) < u.calls:
+		i.calls = append(i.calls, u.callTop)
+	}
+	if u.defined {
+		if u.procedureSet {
+			i.procedures[u.procedure] = u.procedureOld
+		} else {
+			delete(i.procedures, u.procedure)
+		}
+	}
+	return u
+}
+
+func writesCell(in *instruction) bool {
+	if in.end {
+		return false
+	}
+	switch in.expr.(type) {
+	case *ast.ValueIncrementExpression, *ast.ValueDecrementExpression,
+		*ast.MultipleValueIncrementExpression, *ast.MultipleValueDecrementExpression,
+		*ast.ValueChangeExpression, *ast.ValueResetExpression, *ast.InputExpression:
+		return true
+	}
+	return false
+}
+
+// Executed returns the number of instructions executed, which numbers the
+// steps of the history.
+func (i *Interpreter) Executed() int {
+	if i.Config.History == nil {
+		return 0
+	}
+	return i.Config.History.executed
+}
+
+// StepBack undoes the last executed instruction.
+func (i *Interpreter) StepBack(ctx context.Context) (*Stop, error) {
+	if err := i.GoToStep(ctx, i.Executed()-1); err != nil {
+		return nil, err
+	}
+	return &Stop{Reason: StopStep}, nil
+}
+
+// ReverseToWrite runs backwards until just before the last instruction that
+// wrote cell, so that it is the next instruction.
+func (i *Interpreter) ReverseToWrite(ctx context.Context, cell int) (*Stop, error) {
+	h, err := i.history()
+	if err != nil {
+		return nil, err
+	}
+	if cell < 0 || cell >= len(i.Memory) {
+		return nil, fmt.Errorf("%w: cell %d is out of memory", ErrMemoryOverflow, cell)
+	}
+
+	for h.executed > 0 {
+		if len(h.log) == 0 {
+			if err := i.replaySegment(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		value := i.Memory[cell]
+		u := h.undo(i)
+		h.executed--
+		if u.wrote && u.pointer == cell {
+			i.refreshWatches()
+			return &Stop{Reason: StopWatch, Cell: cell, Old: u.old, New: value}, nil
+		}
+	}
+	i.refreshWatches()
+	return nil, fmt.Errorf("%w: cell %d was not written", ErrHistoryExhausted, cell)
+}
+
+// GoToStep moves to the state after n instructions were executed, backwards
+// or forwards.
+func (i *Interpreter) GoToStep(ctx context.Context, n int) error {
+	h, err := i.history()
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return fmt.Errorf("%w: step %d is before the start", ErrHistoryExhausted, n)
+	}
+	defer i.refreshWatches()
+
+	if n < h.executed-len(h.log) {
+		if err := i.restoreCheckpoint(n); err != nil {
+			return err
+		}
+	}
+	for h.executed > n {
+		h.undo(i)
+		h.executed--
+	}
+	for h.executed < n && !i.Finished() {
+		if err := i.exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaySegment refills the undo log with the instructions before the
+// current step from the checkpoint preceding them.
+func (i *Interpreter) replaySegment(ctx context.Context) error {
+	h := i.Config.History
+	n := h.executed
+	if err := i.restoreCheckpoint(n - 1); err != nil {
+		return err
+	}
+	for h.executed < n {
+		if err := i.exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *Interpreter) restoreCheckpoint(n int) error {
+	h := i.Config.History
+	idx := sort.Search(len(h.checkpoints), func(idx int) bool {
+		return h.checkpoints[idx].executed > n
+	}) - 1
+	if idx < 0 {
+		return fmt.Errorf("%w: step %d is no longer recorded", ErrHistoryExhausted, n)
+	}
+
+	cp := h.checkpoints[idx]
+	h.checkpoints = h.checkpoints[:idx+1]
+	h.log = h.log[:0]
+	h.executed = cp.executed
+	i.restore(cp.state)
+	return nil
+}
+
+func (i *Interpreter) history() (*History, error) {
+	if i.Config.History == nil {
+		return nil, ErrNoHistory
+	}
+	if i.Config.Syntax.Fork {
+		return nil, fmt.Errorf("%w: with history", ErrForkNotSupported)
+	}
+	if err := i.prepare(); err != nil {
+		return nil, err
+	}
+	return i.Config.History, nil
+}
+
+func (i *Interpreter) refreshWatches() {
+	for cell := range i.watches {
+		i.watches[cell] = i.Memory[cell]
+	}
+}
+
+// readInput reads a byte, replaying the input of earlier runs through the
+// same steps.
+func (i *Interpreter) readInput(b []byte) error {
+	h := i.Config.History
+	if h != nil && i.threads == nil {
+		if offset := i.usage.input; offset < int64(len(h.input)) {
+			b[0] = h.input[offset]
+			return nil
+		}
+	}
+
+	if _, err := i.reader.Read(b); err != nil {
+		if errors.Is(err, io.EOF) {
+			return ErrInputFinished
+		}
+		return err
+	}
+	if h != nil && i.threads == nil {
+		h.input = append(h.input, b[0])
+	}
+	return nil
+}
+
+// writeOutput writes a byte unless a replay already wrote it.
+func (i *Interpreter) writeOutput(b byte) error {
+	h := i.Config.History
+	if h != nil && i.threads == nil {
+		offset := i.usage.output
+		if offset <= h.written {
+			return nil
+		}
+		h.written = offset
+	}
+
+	return i.emit(b)
+}
@@ -0,0 +1,124 @@
+package interpreter_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/rosylilly/brainfxxk/ast"
+	"github.com/rosylilly/brainfxxk/interpreter"
+	"github.com/rosylilly/brainfxxk/lexer"
+	"github.com/rosylilly/brainfxxk/parser"
+)
+
+func newHistoryInterpreter(t *testing.T, source string, input string, interval int) (*interpreter.Interpreter, *bytes.Buffer) {
+	t.Helper()
+
+	c := &interpreter.Config{
+		Reader:     strings.NewReader(input),
+		MemorySize: 8,
+		Syntax:     lexer.Config{Procedures: true},
+		History:    interpreter.NewHistory(),
+	}
+	c.History.CheckpointInterval = interval
+
+	p, err := parser.ParseWithConfig(strings.NewReader(source), &c.Syntax)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := &bytes.Buffer{}
+	c.Writer = w
+	ip := interpreter.NewInterpreter(p, c)
+	ip.Source = ast.NewSourceMap([]byte(source))
+	return ip, w
+}
+
+func TestInterpreterStepBack(t *testing.T) {
+	ctx := context.Background()
+	source := ",[.,]+(>+<):++[>+<-]>."
+
+	for _, interval := range []int{1, 3, 4096} {
+		ip, w := newHistoryInterpreter(t, source, "abc", interval)
+
+		type state struct {
+			executed int
+			memory   []byte
+			pointer  int
+			count    int
+		}
+		states := []state{}
+		for !ip.Finished() {
+			states = append(states, state{ip.Executed(), slices.Clone(ip.Memory), ip.Pointer, ip.Count()})
+			if _, err := ip.Step(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		output := w.String()
+
+		for n := len(states) - 1; n >= 0; n-- {
+			if _, err := ip.StepBack(ctx); err != nil {
+				t.Fatalf("interval %d: step back to %d: %v", interval, n, err)
+			}
+			s := states[n]
+			if ip.Executed() != s.executed || !bytes.Equal(ip.Memory, s.memory) || ip.Pointer != s.pointer || ip.Count() != s.count {
+				t.Errorf("interval %d: step %d: got %d %v %d %d, expected %+v", interval, n, ip.Executed(), ip.Memory, ip.Pointer, ip.Count(), s)
+			}
+		}
+		if _, err := ip.StepBack(ctx); !errors.Is(err, interpreter.ErrHistoryExhausted) {
+			t.Errorf("got: %v, expected: %v", err, interpreter.ErrHistoryExhausted)
+		}
+
+		if err := ip.GoToStep(ctx, len(states)); err != nil {
+			t.Fatal(err)
+		}
+		if !ip.Finished() {
+			t.Errorf("interval %d: expected to finish", interval)
+		}
+		if w.String() != output {
+			t.Errorf("interval %d: replay changed the output to %q from %q", interval, w.String(), output)
+		}
+
+		if err := ip.GoToStep(ctx, 5); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(ip.Memory, states[5].memory) || ip.Pointer != states[5].pointer {
+			t.Errorf("interval %d: goto 5: got %v %d, expected %+v", interval, ip.Memory, ip.Pointer, states[5])
+		}
+	}
+}
+
+func TestInterpreterReverseToWrite(t *testing.T) {
+	ctx := context.Background()
+	ip, _ := newHistoryInterpreter(t, "+++>++>+<<[->>+<<]", "", 2)
+
+	if _, err := ip.Continue(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	stop, err := ip.ReverseToWrite(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stop.Reason != interpreter.StopWatch || stop.Cell != 1 || stop.Old != 0 || stop.New != 2 {
+		t.Errorf("got: %+v", stop)
+	}
+	if pos, _ := ip.CurrentPos(); pos != 4 {
+		t.Errorf("got position %d, expected 4", pos)
+	}
+
+	if _, err := ip.ReverseToWrite(ctx, 1); !errors.Is(err, interpreter.ErrHistoryExhausted) {
+		t.Errorf("got: %v, expected: %v", err, interpreter.ErrHistoryExhausted)
+	}
+	if ip.Executed() != 0 {
+		t.Errorf("got step %d, expected 0", ip.Executed())
+	}
+
+	ip, _ = newDebugInterpreter(t, "+")
+	if _, err := ip.StepBack(ctx); !errors.Is(err, interpreter.ErrNoHistory) {
+		t.Errorf("got: %v, expected: %v", err, interpreter.ErrNoHistory)
+	}
+}
@@ -1,7 +1,10 @@
 package interpreter
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
@@ -11,9 +14,20 @@ import (
 	"github.com/rosylilly/brainfxxk/parser"
 )
 
+const (
+	debugDumpRadius     = 4
+	defaultMaxCallDepth = 1024
+	// contextCheckInterval is how many steps run between looks at the
+	// context, checking it costs more than most instructions.
+	contextCheckInterval = 1024
+)
+
 var (
 	ErrInputFinished  = fmt.Errorf("input finished")
 	ErrMemoryOverflow = fmt.Errorf("memory overflow")
+
+	ErrUndefinedProcedure = fmt.Errorf("undefined procedure")
+	ErrCallDepthExceeded  = fmt.Errorf("call depth exceeded")
 )
 
 type Interpreter struct {
@@ -21,152 +35,650 @@ type Interpreter struct {
 	Config  *Config
 	Memory  []byte
 	Pointer int
+	Source  *ast.SourceMap
+
+	reader io.Reader
+	writer io.Writer
+	buffer *bufio.Writer
+
+	code []instruction
+	// base is where the code of the current program starts. Eval keeps the
+	// code before it, procedures defined there may still be called.
+	base   int
+	pc     int
+	count  int
+	loaded bool
+	ticks  int
+	usage  *usage
+	// charged is the number of steps charged by the current instruction.
+	charged int
+
+	// committed is the count of fully executed top-level expressions,
+	// which is what Run reports when execution stops early.
+	committed int
+	// maxPointer is the rightmost cell the pointer reached.
+	maxPointer int
+	passStats  []optimizer.PassStats
+
+	procedures map[byte]int
+	calls      []int
+
+	breakpoints map[int]bool
+	watches     map[int]byte
+
+	threads  *threads
+	children []*Interpreter
+	output   *bytes.Buffer
 }
 
 func Run(ctx context.Context, s io.Reader, c *Config) (int, error) {
-	p, err := parser.Parse(s)
+	i, err := Load(s, c)
 	if err != nil {
 		return 0, err
 	}
+	return i.Run(ctx)
+}
+
+// Load parses the source into an interpreter that knows the source lines.
+func Load(s io.Reader, c *Config) (*Interpreter, error) {
+	src := &bytes.Buffer{}
+	p, err := parser.ParseWithConfig(io.TeeReader(s, src), &c.Syntax)
+	if err != nil {
+		return nil, err
+	}
 
-	return NewInterpreter(p, c).Run(ctx)
+	i := NewInterpreter(p, c)
+	i.Source = ast.NewSourceMap(src.Bytes())
+	return i, nil
 }
 
 func NewInterpreter(p *ast.Program, c *Config) *Interpreter {
-	return &Interpreter{
+	// The input embedded in the program is only used when no other input
+	// is supplied.
+	reader := c.Reader
+	if reader == nil {
+		reader = bytes.NewReader(p.Input)
+	}
+
+	i := &Interpreter{
 		Program: p,
 		Config:  c,
 		Memory:  make([]byte, c.MemorySize),
 		Pointer: 0,
+
+		reader: reader,
+		writer: c.Writer,
+
+		breakpoints: map[int]bool{},
+		watches:     map[int]byte{},
 	}
+	if c.OutputBufferSize > 0 && c.Writer != nil {
+		i.buffer = bufio.NewWriterSize(c.Writer, c.OutputBufferSize)
+		i.writer = i.buffer
+	}
+	return i
 }
 
+// Run executes the program to the end, continuing from where stepping or a
+// restored snapshot left off.
 func (i *Interpreter) Run(ctx context.Context) (int, error) {
-	p, err := optimizer.NewOptimizer().Optimize(i.Program)
-	if err != nil {
-		return 0, err
-	}
-
 	if i.Config.AstInfo {
+		p, err := i.newOptimizer().Optimize(i.Program)
+		if err != nil {
+			return 0, err
+		}
 		ast.PrintASTList(p.Expressions)
 		return 0, nil
 	}
 
-	count, err := i.runExpressions(ctx, p.Expressions)
-	if errors.Is(err, ErrInputFinished) && !i.Config.RaiseErrorOnEOF {
-		return count, nil
+	if err := i.prepare(); err != nil {
+		return 0, err
+	}
+
+	count, err := i.run(ctx)
+	// the output is flushed however the run ended.
+	if ferr := i.Flush(); ferr != nil && err == nil {
+		err = ferr
 	}
 	return count, err
 }
 
-func (i *Interpreter) runExpressions(ctx context.Context, exprs []ast.Expression) (int, error) {
-	count := 0
-	for _, expr := range exprs {
-		exprCount, err := i.runExpression(ctx, expr)
+func (i *Interpreter) run(ctx context.Context) (int, error) {
+	if i.Config.Syntax.Fork {
+		return i.runThreads(ctx)
+	}
+	for !i.Finished() {
+		if err := i.exec(ctx); err != nil {
+			return i.committed, err
+		}
+	}
+	return i.count, nil
+}
+
+// Eval runs another program on the current tape, keeping Memory, Pointer and
+// the procedures defined so far.
+func (i *Interpreter) Eval(ctx context.Context, p *ast.Program) (int, error) {
+	i.Program = p
+	i.loaded = false
+	i.base = len(i.code)
+	return i.Run(ctx)
+}
+
+func (i *Interpreter) load(p *ast.Program) {
+	i.code = compile(i.code[:i.base], p.Expressions)
+	if i.Config.Profiler != nil {
+		i.Config.Profiler.attach(i.code, i.Source)
+	}
+	if i.Config.History != nil {
+		i.Config.History.reset()
+	}
+	i.pc = i.base
+	i.count = 0
+	i.usage = &usage{}
+	i.committed = 0
+	i.maxPointer = i.Pointer
+	if i.base == 0 {
+		i.procedures = map[byte]int{}
+	}
+	i.calls = nil
+	i.loaded = true
+}
+
+func (i *Interpreter) prepare() error {
+	if i.loaded {
+		return nil
+	}
+	if i.Config.Unoptimized {
+		i.load(i.Program)
+		return nil
+	}
+
+	o := i.newOptimizer()
+	p, err := o.Optimize(i.Program)
+	if err != nil {
+		return err
+	}
+
+	i.load(p)
+	i.passStats = o.Stats
+	return nil
+}
+
+func (i *Interpreter) newOptimizer() *optimizer.Optimizer {
+	if i.Config.Optimizer != nil {
+		return optimizer.NewOptimizerWithConfig(i.Config.Optimizer)
+	}
+	return optimizer.NewOptimizer()
+}
+
+func (i *Interpreter) Finished() bool {
+	return i.loaded && i.pc >= len(i.code)
+}
+
+func (i *Interpreter) Count() int {
+	return i.count
+}
+
+// Nodes returns the number of expressions in the optimized program, not
+// counting comments.
+func (i *Interpreter) Nodes() int {
+	nodes := 0
+	for idx := i.base; idx < len(i.code); idx++ {
+		if !i.code[idx].end {
+			nodes++
+		}
+	}
+	return nodes
+}
+
+// PassStats returns the statistics of the optimization passes the program
+// went through, nil when it runs unoptimized.
+func (i *Interpreter) PassStats() []optimizer.PassStats {
+	return i.passStats
+}
+
+func (i *Interpreter) MaxPointer() int {
+	return i.maxPointer
+}
+
+func (i *Interpreter) maxCallDepth() int {
+	if i.Config.MaxCallDepth > 0 {
+		return i.Config.MaxCallDepth
+	}
+	return defaultMaxCallDepth
+}
+
+func (i *Interpreter) exec(ctx context.Context) error {
+	pc := i.pc
+	pointer := i.Pointer
+	var before byte
+	if i.Config.Trace != nil && pointer >= 0 && pointer < len(i.Memory) {
+		before = i.Memory[pointer]
+	}
+	i.charged = 0
+	history := i.Config.History
+	if history != nil && i.threads == nil {
+		history.record(i)
+	}
+	err := i.step(ctx)
+	if i.Config.Profiler != nil && i.charged > 0 {
+		i.Config.Profiler.record(i.code, pc, i.pc, i.charged)
+	}
+	if i.Config.Trace != nil && err == nil {
+		if err := i.trace(&i.code[pc], pointer, before); err != nil {
+			return err
+		}
+	}
+	if err != nil && errors.Is(err, ErrInputFinished) && !i.Config.RaiseErrorOnEOF {
+		i.pc = len(i.code)
+		i.count = i.committed
+		err = nil
+	}
+	if history != nil && i.threads == nil {
 		if err != nil {
-			return count, err
+			// leave the machine as it was before the failed instruction.
+			history.undo(i)
+		} else {
+			history.executed++
 		}
-		count += exprCount
 	}
-	return count, nil
+	return err
 }
 
-func (i *Interpreter) runExpression(ctx context.Context, expr ast.Expression) (int, error) {
-	select {
-	case <-ctx.Done():
-		return 0, ctx.Err()
+func (i *Interpreter) step(ctx context.Context) error {
+	if i.ticks%contextCheckInterval == 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+	}
+	i.ticks++
+
+	in := &i.code[i.pc]
+	if in.depth == 0 && !in.end {
+		i.committed = i.count
+	}
+	if in.cell && (i.Pointer < 0 || i.Pointer >= len(i.Memory)) {
+		start, end := in.expr.StartPos(), in.expr.EndPos()
+		if in.end {
+			start = end
+		}
+		return fmt.Errorf("%w: %d is outside the tape, on %d:%d", ErrMemoryOverflow, i.Pointer, start, end)
+	}
+	if in.end {
+		if err := i.charge(in.size); err != nil {
+			return err
+		}
+		switch in.expr.(type) {
+		case *ast.WhileExpression:
+			if i.Memory[i.Pointer] != 0 {
+				i.pc = in.jump + 1
+			} else {
+				i.pc++
+			}
+		case *ast.ProcedureExpression:
+			i.pc = i.calls[len(i.calls)-1]
+			i.calls = i.calls[:len(i.calls)-1]
+		}
+		return nil
+	}
+
+	switch in.expr.(type) {
+	case *ast.ValueResetExpression, *ast.ZeroSearchExpression:
+		// charged by runExpression once the iterations are known.
 	default:
+		if err := i.charge(in.size); err != nil {
+			return err
+		}
 	}
 
-	count := 1
+	if err := i.runExpression(in); err != nil {
+		return err
+	}
+	i.pc++
+	i.count++
+	return nil
+}
 
-	switch e := expr.(type) {
+func (i *Interpreter) runExpression(in *instruction) error {
+	switch e := in.expr.(type) {
 	case *ast.PointerIncrementExpression:
-		if i.Pointer == len(i.Memory)-1 && i.Config.RaiseErrorOnOverflow {
-			return count, fmt.Errorf("%w: %d to pointer overflow, on %d:%d", ErrMemoryOverflow, i.Pointer, e.StartPos(), e.EndPos())
-		}
-		i.Pointer += 1
+		return i.move(1, e)
 	case *ast.MultiplePointerIncrementExpression:
-		if i.Pointer == len(i.Memory)-1 && i.Config.RaiseErrorOnOverflow {
-			return count, fmt.Errorf("%w: %d to pointer overflow, on %d:%d", ErrMemoryOverflow, i.Pointer, e.StartPos(), e.EndPos())
-		}
-		i.Pointer += e.Count
+		return i.moveEach(e.Count, e.Expressions, e)
 	case *ast.PointerDecrementExpression:
-		if i.Pointer == 0 && i.Config.RaiseErrorOnOverflow {
-			return count, fmt.Errorf("%w: %d to pointer underflow, on %d:%d", ErrMemoryOverflow, i.Pointer, e.StartPos(), e.EndPos())
-		}
-		i.Pointer -= 1
+		return i.move(-1, e)
 	case *ast.MultiplePointerDecrementExpression:
-		if i.Pointer == len(i.Memory)-1 && i.Config.RaiseErrorOnOverflow {
-			return count, fmt.Errorf("%w: %d to pointer overflow, on %d:%d", ErrMemoryOverflow, i.Pointer, e.StartPos(), e.EndPos())
-		}
-		i.Pointer -= e.Count
+		return i.moveEach(-e.Count, e.Expressions, e)
 	case *ast.PointerMoveExpression:
-		if i.Pointer == len(i.Memory)-1 && i.Config.RaiseErrorOnOverflow {
-			return count, fmt.Errorf("%w: %d to pointer overflow, on %d:%d", ErrMemoryOverflow, i.Pointer, e.StartPos(), e.EndPos())
-		}
-		i.Pointer += e.Count
+		return i.moveEach(e.Count, e.Expressions, e)
 	case *ast.ValueIncrementExpression:
 		if i.Memory[i.Pointer] == 255 && i.Config.RaiseErrorOnOverflow {
-			return count, fmt.Errorf("%w: %d to memory overflow, on %d:%d", ErrMemoryOverflow, i.Pointer, e.StartPos(), e.EndPos())
+			return fmt.Errorf("%w: %d to memory overflow, on %d:%d", ErrMemoryOverflow, i.Pointer, e.StartPos(), e.EndPos())
 		}
 		i.Memory[i.Pointer] += 1
 	case *ast.MultipleValueIncrementExpression:
 		if i.Memory[i.Pointer] == 255 && i.Config.RaiseErrorOnOverflow {
-			return count, fmt.Errorf("%w: %d to memory overflow, on %d:%d", ErrMemoryOverflow, i.Pointer, e.StartPos(), e.EndPos())
+			return fmt.Errorf("%w: %d to memory overflow, on %d:%d", ErrMemoryOverflow, i.Pointer, e.StartPos(), e.EndPos())
 		}
 		i.Memory[i.Pointer] += byte(e.Count)
 	case *ast.ValueDecrementExpression:
 		if i.Memory[i.Pointer] == 0 && i.Config.RaiseErrorOnOverflow {
-			return count, fmt.Errorf("%w: %d to memory underflow, on %d:%d", ErrMemoryOverflow, i.Pointer, e.StartPos(), e.EndPos())
+			return fmt.Errorf("%w: %d to memory underflow, on %d:%d", ErrMemoryOverflow, i.Pointer, e.StartPos(), e.EndPos())
 		}
 		i.Memory[i.Pointer] -= 1
 	case *ast.MultipleValueDecrementExpression:
 		if i.Memory[i.Pointer] == 255 && i.Config.RaiseErrorOnOverflow {
-			return count, fmt.Errorf("%w: %d to memory overflow, on %d:%d", ErrMemoryOverflow, i.Pointer, e.StartPos(), e.EndPos())
+			return fmt.Errorf("%w: %d to memory overflow, on %d:%d", ErrMemoryOverflow, i.Pointer, e.StartPos(), e.EndPos())
 		}
 		i.Memory[i.Pointer] -= byte(e.Count)
 	case *ast.ValueChangeExpression:
 		if i.Memory[i.Pointer] == 255 && i.Config.RaiseErrorOnOverflow {
-			return count, fmt.Errorf("%w: %d to memory overflow, on %d:%d", ErrMemoryOverflow, i.Pointer, e.StartPos(), e.EndPos())
+			return fmt.Errorf("%w: %d to memory overflow, on %d:%d", ErrMemoryOverflow, i.Pointer, e.StartPos(), e.EndPos())
 		}
 		i.Memory[i.Pointer] += byte(e.Count)
 	case *ast.ValueResetExpression:
+		if e.Loop != nil {
+			return i.clear(e)
+		}
+		// "[-]" runs "-]" once per unit of the cell.
+		if err := i.charge(1 + 2*int(i.Memory[i.Pointer])); err != nil {
+			return err
+		}
 		i.Memory[i.Pointer] = 0
 	case *ast.ZeroSearchExpression:
-		for {
-			if i.Memory[i.Pointer] == 0 {
-				break
-			}
-			i.Pointer += e.SearchWindow
-			if i.Pointer == len(i.Memory)-1 && i.Config.RaiseErrorOnOverflow {
-				return count, fmt.Errorf("%w: %d to pointer overflow, on %d:%d", ErrMemoryOverflow, i.Pointer, e.StartPos(), e.EndPos())
-			}
-			if i.Pointer == 0 && i.Config.RaiseErrorOnOverflow {
-				return count, fmt.Errorf("%w: %d to pointer underflow, on %d:%d", ErrMemoryOverflow, i.Pointer, e.StartPos(), e.EndPos())
+		_, right := e.Reach()
+		growing := i.Config.MaxMemorySize > i.Config.MemorySize
+		if i.Config.RaiseErrorOnOverflow || (growing && right > max(0, e.SearchWindow)) {
+			return i.scanEach(e)
+		}
+		pointer, moves, err := i.scan(i.Pointer, e.SearchWindow, right)
+		// the body holds Size instructions, plus "]".
+		steps := 1 + moves*(e.Size()+1)
+		if errors.Is(err, ErrMemoryOverflow) {
+			// the last "]" fails to read the cell outside the tape.
+			steps--
+			err = fmt.Errorf("%w, on %d:%d", err, e.EndPos(), e.EndPos())
+		}
+		if cerr := i.charge(steps); cerr != nil {
+			return cerr
+		}
+		if err != nil {
+			if !errors.Is(err, ErrMemoryLimitExceeded) {
+				// stop where the loop would have stopped.
+				i.Pointer = pointer
 			}
+			return err
 		}
+		i.Pointer = pointer
 	case *ast.OutputExpression:
-		if _, err := i.Config.Writer.Write([]byte{i.Memory[i.Pointer]}); err != nil {
-			return count, err
+		if err := i.chargeOutput(1); err != nil {
+			return err
+		}
+		if err := i.writeOutput(i.Memory[i.Pointer]); err != nil {
+			return err
 		}
 	case *ast.InputExpression:
-		b := make([]byte, 1)
-		if _, err := i.Config.Reader.Read(b); err != nil {
-			if errors.Is(err, io.EOF) {
-				return count, ErrInputFinished
+		if i.Config.FlushPolicy&FlushOnInput != 0 {
+			if err := i.Flush(); err != nil {
+				return err
 			}
-			return count, err
+		}
+		b := make([]byte, 1)
+		if err := i.unlocked(func() error { return i.readInput(b) }); err != nil {
+			return err
+		}
+		// only bytes actually read count, so EOF right at the limit is fine.
+		if err := i.chargeInput(1); err != nil {
+			return err
 		}
 		i.Memory[i.Pointer] = b[0]
+	case *ast.DebugExpression:
+		if i.Config.DebugWriter != nil {
+			if err := i.WriteTape(i.Config.DebugWriter, debugDumpRadius); err != nil {
+				return err
+			}
+		}
 	case *ast.WhileExpression:
-		for i.Memory[i.Pointer] != 0 {
-			bodyCount, err := i.runExpressions(ctx, e.Body)
-			if err != nil {
-				return count, err
+		if i.Memory[i.Pointer] == 0 {
+			i.pc = in.jump
+		}
+	case *ast.ProcedureExpression:
+		i.procedures[i.Memory[i.Pointer]] = i.pc + 1
+		i.pc = in.jump
+	case *ast.CallExpression:
+		target, ok := i.procedures[i.Memory[i.Pointer]]
+		if !ok {
+			return fmt.Errorf("%w: %d, on %d:%d", ErrUndefinedProcedure, i.Memory[i.Pointer], e.StartPos(), e.EndPos())
+		}
+		if len(i.calls) >= i.maxCallDepth() {
+			return fmt.Errorf("%w: %d, on %d:%d", ErrCallDepthExceeded, len(i.calls), e.StartPos(), e.EndPos())
+		}
+		i.calls = append(i.calls, i.pc+1)
+		// step advances pc past the call, so land just before the body.
+		i.pc = target - 1
+	case *ast.ForkExpression:
+		if err := i.fork(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scan moves pointer by window until it is on a zero cell, growing the tape
+// on the way when it may. Every move goes through the cells up to right of
+// where it starts. It returns where it stopped and the number of moves.
+func (i *Interpreter) scan(pointer, window, right int) (int, int, error) {
+	moves := 0
+	for {
+		start := pointer
+		n, found := 0, false
+		switch window {
+		case 1:
+			idx := bytes.IndexByte(i.Memory[pointer:], 0)
+			n, found = idx, idx >= 0
+			if !found {
+				n = len(i.Memory) - pointer
+			}
+		case -1:
+			idx := lastZero(i.Memory[:pointer+1])
+			n, found = pointer-idx, idx >= 0
+		default:
+			for p := pointer; p >= 0 && p < len(i.Memory); p += window {
+				if i.Memory[p] == 0 {
+					found = true
+					break
+				}
+				n++
+			}
+		}
+		pointer += n * window
+		moves += n
+
+		far := pointer
+		if n > 0 {
+			// the last move goes furthest right, or the first one when
+			// searching to the left.
+			furthest := start
+			if window > 0 {
+				furthest = pointer - window
+			}
+			far = max(far, furthest+right)
+		}
+		if err := i.reach(far); err != nil {
+			return pointer, moves, err
+		}
+		if found {
+			return pointer, moves, nil
+		}
+		if pointer < 0 || pointer >= len(i.Memory) {
+			return pointer, moves, fmt.Errorf("%w: %d is outside the tape", ErrMemoryOverflow, pointer)
+		}
+		// the tape grew to hold pointer, search on.
+	}
+}
+
+// scanEach runs the loop move by move, so that each move can overflow or grow
+// the tape as unoptimized.
+func (i *Interpreter) scanEach(e *ast.ZeroSearchExpression) error {
+	exprs := []ast.Expression{}
+	if e.Loop == nil {
+		// the moves follow "[" in the source.
+		for n := 1; n <= max(e.SearchWindow, -e.SearchWindow); n++ {
+			if e.SearchWindow > 0 {
+				exprs = append(exprs, &ast.PointerIncrementExpression{Pos: e.StartPos() + n})
+			} else {
+				exprs = append(exprs, &ast.PointerDecrementExpression{Pos: e.StartPos() + n})
+			}
+		}
+	} else {
+		for _, expr := range e.Loop.Body {
+			switch b := expr.(type) {
+			case *ast.PointerMoveExpression:
+				exprs = append(exprs, b.Expressions...)
+			case *ast.PointerIncrementExpression, *ast.PointerDecrementExpression:
+				exprs = append(exprs, b)
+			}
+		}
+	}
+
+	if err := i.charge(1); err != nil {
+		return err
+	}
+	for i.Memory[i.Pointer] != 0 {
+		for _, expr := range exprs {
+			if err := i.charge(1); err != nil {
+				return err
+			}
+			if err := i.runExpression(&instruction{expr: expr}); err != nil {
+				return err
+			}
+		}
+		if i.Pointer < 0 || i.Pointer >= len(i.Memory) {
+			return fmt.Errorf("%w: %d is outside the tape, on %d:%d", ErrMemoryOverflow, i.Pointer, e.EndPos(), e.EndPos())
+		}
+		if err := i.charge(1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lastZero returns the index of the last zero in b, or -1. Unlike
+// bytes.LastIndexByte it checks eight bytes at a time.
+func lastZero(b []byte) int {
+	end := len(b)
+	for ; end >= 8; end -= 8 {
+		w := binary.LittleEndian.Uint64(b[end-8 : end])
+		// nonzero when some byte of w is zero.
+		if (w-0x0101010101010101)&^w&0x8080808080808080 != 0 {
+			break
+		}
+	}
+	for idx := end - 1; idx >= 0; idx-- {
+		if b[idx] == 0 {
+			return idx
+		}
+	}
+	return -1
+}
+
+// clear runs a clear loop with an odd step, which reaches zero after the
+// iterations that make up for the cell.
+func (i *Interpreter) clear(e *ast.ValueResetExpression) error {
+	if i.Config.RaiseErrorOnOverflow {
+		return i.clearEach(e)
+	}
+	cell := i.Memory[i.Pointer]
+	iterations := int(-cell * inverse(byte(e.Step())))
+	if err := i.charge(1 + iterations*(e.Size()+1)); err != nil {
+		return err
+	}
+	i.Memory[i.Pointer] = 0
+	return nil
+}
+
+// clearEach runs the loop instruction by instruction, so that each one can
+// overflow as unoptimized.
+func (i *Interpreter) clearEach(e *ast.ValueResetExpression) error {
+	exprs := []ast.Expression{}
+	for _, expr := range e.Loop.Body {
+		switch b := expr.(type) {
+		case *ast.ValueChangeExpression:
+			exprs = append(exprs, b.Expressions...)
+		case *ast.ValueIncrementExpression, *ast.ValueDecrementExpression:
+			exprs = append(exprs, b)
+		}
+	}
+
+	if err := i.charge(1); err != nil {
+		return err
+	}
+	for i.Memory[i.Pointer] != 0 {
+		for _, expr := range exprs {
+			if err := i.charge(1); err != nil {
+				return err
+			}
+			if err := i.runExpression(&instruction{expr: expr}); err != nil {
+				return err
+			}
+		}
+		if err := i.charge(1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inverse returns the multiplicative inverse of the odd n modulo 256.
+func inverse(n byte) byte {
+	// each round doubles the correct low bits, n is its own inverse
+	// modulo 8.
+	x := n
+	x *= 2 - n*x
+	x *= 2 - n*x
+	return x
+}
+
+// move moves the pointer by n. The pointer may leave the tape unless
+// RaiseErrorOnOverflow is set, using a cell there fails instead.
+func (i *Interpreter) move(n int, e ast.Expression) error {
+	pointer := i.Pointer + n
+	if n > 0 {
+		if err := i.reach(pointer); err != nil {
+			return err
+		}
+	}
+	if i.Config.RaiseErrorOnOverflow {
+		if pointer >= len(i.Memory) {
+			return fmt.Errorf("%w: %d to pointer overflow, on %d:%d", ErrMemoryOverflow, i.Pointer, e.StartPos(), e.EndPos())
+		}
+		if pointer < 0 {
+			return fmt.Errorf("%w: %d to pointer underflow, on %d:%d", ErrMemoryOverflow, i.Pointer, e.StartPos(), e.EndPos())
+		}
+	}
+	i.Pointer = pointer
+	return nil
+}
+
+// moveEach moves the pointer by n, the sum of the moves in exprs. Under
+// RaiseErrorOnOverflow each move has to stay on the tape, as unoptimized.
+func (i *Interpreter) moveEach(n int, exprs []ast.Expression, e ast.Expression) error {
+	if !i.Config.RaiseErrorOnOverflow {
+		return i.move(n, e)
+	}
+	for _, expr := range exprs {
+		switch expr.(type) {
+		case *ast.PointerIncrementExpression:
+			if err := i.move(1, expr); err != nil {
+				return err
+			}
+		case *ast.PointerDecrementExpression:
+			if err := i.move(-1, expr); err != nil {
+				return err
 			}
-			count += bodyCount
 		}
 	}
-	return count, nil
+	return nil
 }
@@ -5,11 +5,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/rosylilly/brainfxxk/ast"
 	"github.com/rosylilly/brainfxxk/interpreter"
+	"github.com/rosylilly/brainfxxk/lexer"
+	"github.com/rosylilly/brainfxxk/parser"
 )
 
 func TestInterpreter(t *testing.T) {
@@ -20,7 +26,9 @@ func TestInterpreter(t *testing.T) {
 		source   string
 		input    string
 		expected string
-		count    int
+		// count is the number of executed expressions of the optimized
+		// program, which has no comments left to execute.
+		count int
 	}{
 		{
 			source:   "++++++++++[>+++++++>++++++++++>+++>+<<<<-]>++.>+.+++++++..+++.>++.<<+++++++++++++++.>.+++.------.--------.>+.>.",
@@ -37,7 +45,7 @@ func TestInterpreter(t *testing.T) {
 +++++.+.<.>.--.`,
 			input:    "",
 			expected: "2 3 5 7 11 13 17 19 23 29 31 37 41 43 47 53 59 61 67 71 73 79 83 89 97",
-			count:    242,
+			count:    231,
 		},
 		{
 			source:   "+[>,.<]",
@@ -54,7 +62,7 @@ func TestInterpreter(t *testing.T) {
 >]>.<<<<<<<<<<<]`,
 			input:    "",
 			expected: makeFizzBuzz(100),
-			count:    5952,
+			count:    5570,
 		},
 	}
 
@@ -109,6 +117,215 @@ func TestInterpreterInfinityRead(t *testing.T) {
 	}
 }
 
+func TestInterpreterDebugDump(t *testing.T) {
+	ctx := context.Background()
+
+	w := &bytes.Buffer{}
+	d := &bytes.Buffer{}
+	c := &interpreter.Config{
+		Writer:      w,
+		Reader:      strings.NewReader(""),
+		MemorySize:  3,
+		Syntax:      lexer.Config{DebugDump: true},
+		DebugWriter: d,
+	}
+
+	if _, err := interpreter.Run(ctx, strings.NewReader("++>+#."), c); err != nil {
+		t.Fatal(err)
+	}
+
+	if w.String() != "\x01" {
+		t.Errorf("output: got: %q, expected: %q", w.String(), "\x01")
+	}
+	expected := "pointer: 1\n      0:   2\n>     1:   1\n      2:   0\n"
+	if d.String() != expected {
+		t.Errorf("dump: got: %q, expected: %q", d.String(), expected)
+	}
+}
+
+func TestInterpreterProgramInput(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		reader   io.Reader
+		expected string
+	}{
+		{reader: nil, expected: "abc"},
+		{reader: strings.NewReader("xyz"), expected: "xyz"},
+	}
+
+	for _, tc := range testCases {
+		w := &bytes.Buffer{}
+		c := &interpreter.Config{
+			Writer:     w,
+			Reader:     tc.reader,
+			MemorySize: 30000,
+			Syntax:     lexer.Config{InputSeparator: true},
+		}
+
+		if _, err := interpreter.Run(ctx, strings.NewReader(",[.,]!abc"), c); err != nil {
+			t.Fatal(err)
+		}
+		if w.String() != tc.expected {
+			t.Errorf("got: %q, expected: %q", w.String(), tc.expected)
+		}
+	}
+}
+
+func TestInterpreterEval(t *testing.T) {
+	ctx := context.Background()
+
+	w := &bytes.Buffer{}
+	ip := interpreter.NewInterpreter(&ast.Program{}, &interpreter.Config{
+		Writer:     w,
+		MemorySize: 8,
+	})
+
+	for _, line := range []string{"+++>++", "<[->+<]", ">."} {
+		p, err := parser.Parse(strings.NewReader(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ip.Eval(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if w.String() != "\x05" {
+		t.Errorf("got: %q, expected: %q", w.String(), "\x05")
+	}
+	if ip.Pointer != 1 {
+		t.Errorf("got pointer %d, expected 1", ip.Pointer)
+	}
+}
+
+func TestInterpreterEvalProcedure(t *testing.T) {
+	ctx := context.Background()
+
+	w := &bytes.Buffer{}
+	config := &interpreter.Config{
+		Writer:     w,
+		MemorySize: 8,
+		Syntax:     lexer.Config{Procedures: true},
+	}
+	ip := interpreter.NewInterpreter(&ast.Program{}, config)
+
+	// the procedure defined by the first line is called by the last one.
+	for _, line := range []string{"+(>+.<)", "-", "+:"} {
+		p, err := parser.ParseWithConfig(strings.NewReader(line), &config.Syntax)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ip.Eval(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if w.String() != "\x01" {
+		t.Errorf("got: %q, expected: %q", w.String(), "\x01")
+	}
+}
+
+func TestInterpreterProcedures(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		source   string
+		depth    int
+		expected string
+		err      error
+	}{
+		{
+			source:   "+(>.+<)>>++++++++[<++++++++>-]<+<:::",
+			expected: "ABC",
+		},
+		{
+			// procedure 1 prints and recurses while the next cell is non-zero.
+			source:   "+(>.-[<:>]<)>+++<:",
+			expected: "\x03\x02\x01",
+		},
+		{
+			source: ":",
+			err:    interpreter.ErrUndefinedProcedure,
+		},
+		{
+			source: "(:):",
+			depth:  8,
+			err:    interpreter.ErrCallDepthExceeded,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			c := &interpreter.Config{
+				Writer:       w,
+				Reader:       strings.NewReader(""),
+				MemorySize:   30000,
+				Syntax:       lexer.Config{Procedures: true},
+				MaxCallDepth: tc.depth,
+			}
+
+			_, err := interpreter.Run(ctx, strings.NewReader(tc.source), c)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got: %v, expected: %v", err, tc.err)
+			}
+			if w.String() != tc.expected {
+				t.Errorf("got: %q, expected: %q", w.String(), tc.expected)
+			}
+		})
+	}
+}
+
+func TestInterpreterMaxSteps(t *testing.T) {
+	ctx := context.Background()
+
+	testCases := []struct {
+		source string
+		steps  int
+	}{
+		{source: "++[>+<-]", steps: 13},
+		{source: "+++[-]", steps: 10},
+		{source: "++[+]", steps: 511},
+		{source: "+++[---]", steps: 8},
+		{source: "+[- x ]", steps: 4},
+		{source: "+>>+<<[>>]", steps: 13},
+		{source: "+(>+<)::", steps: 12},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			for _, maxSteps := range []int{0, tc.steps, tc.steps - 1} {
+				p, err := parser.ParseWithConfig(strings.NewReader(tc.source), &lexer.Config{Procedures: true})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				ip := interpreter.NewInterpreter(p, &interpreter.Config{
+					Writer:     &bytes.Buffer{},
+					Reader:     strings.NewReader(""),
+					MemorySize: 30000,
+					MaxSteps:   maxSteps,
+				})
+				_, err = ip.Run(ctx)
+
+				if maxSteps == tc.steps-1 {
+					if !errors.Is(err, interpreter.ErrStepLimitExceeded) {
+						t.Errorf("max steps %d: got: %v, expected: %v", maxSteps, err, interpreter.ErrStepLimitExceeded)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if ip.Steps() != tc.steps {
+					t.Errorf("steps: got: %v, expected: %v", ip.Steps(), tc.steps)
+				}
+			}
+		})
+	}
+}
+
 func makeFizzBuzz(count int) string {
 	var b strings.Builder
 	for i := 1; i <= count; i++ {
@@ -130,3 +347,252 @@ func fizzBuzz(n int) string {
 		return fmt.Sprint(n)
 	}
 }
+
+func TestInterpreterClearLoops(t *testing.T) {
+	testCases := []struct {
+		source   string
+		raise    bool
+		expected error
+	}{
+		{source: "+++[+]"},
+		{source: "+++[---]"},
+		{source: "+[-+-]"},
+		{source: "+++[+]", raise: true, expected: interpreter.ErrMemoryOverflow},
+		{source: "+++[---]", raise: true},
+		{source: "++++[---]", raise: true, expected: interpreter.ErrMemoryOverflow},
+		{source: "+[-+-]", raise: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%s raise=%v", tc.source, tc.raise), func(t *testing.T) {
+			var steps []int
+			for _, unoptimized := range []bool{true, false} {
+				ip, err := interpreter.Load(strings.NewReader(tc.source), &interpreter.Config{
+					Writer:               io.Discard,
+					MemorySize:           4,
+					RaiseErrorOnOverflow: tc.raise,
+					Unoptimized:          unoptimized,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = ip.Run(context.Background())
+				if !errors.Is(err, tc.expected) || (err == nil) != (tc.expected == nil) {
+					t.Errorf("unoptimized=%v: got: %v, expected: %v", unoptimized, err, tc.expected)
+				}
+				if err == nil && ip.Memory[0] != 0 {
+					t.Errorf("unoptimized=%v: cell %d is not cleared", unoptimized, ip.Memory[0])
+				}
+				steps = append(steps, ip.Steps())
+			}
+			if steps[0] != steps[1] {
+				t.Errorf("steps: got %d optimized, expected %d", steps[1], steps[0])
+			}
+		})
+	}
+}
+
+func TestInterpreterZeroSearch(t *testing.T) {
+	testCases := []struct {
+		name          string
+		source        string
+		maxMemorySize int
+		raise         bool
+	}{
+		{name: "right", source: "+>+>+>+>+>+>+>+>+>+>+<<<<<<<<<<[>]"},
+		{name: "left", source: ">>+>+>+>+>+>+>+>+>+>+>+>+[<]"},
+		{name: "left in a word", source: ">>>>>>>>>>>>>>>>>>>>+>+>+[<]"},
+		{name: "by three", source: "+>>>+>>>+>>>+<<<<<<<<<[>>>]+[<<<]"},
+		{name: "with comments", source: "+>+>+<<[>x]+[<y]"},
+		{name: "off the right", source: "+>+>+>+>+>+>+>+<<<<<<<[>]"},
+		{name: "off the left", source: "+>+>+[<]"},
+		{name: "off the right by three", source: "+>>>+>>>+<<<<<<[>>>]"},
+		{name: "growing tape", source: "+>+>+>+>+>+>+>+<<<<<<<[>]+", maxMemorySize: 64},
+		{name: "growing tape by three", source: "+>>>+>>>+<<<<<<[>>>]+", maxMemorySize: 64},
+		{name: "memory limit", source: "+>+>+>+>+>+>+>+<<<<<<<[>]", maxMemorySize: 9},
+		{name: "back and forth", source: "+>+>+>+<<<[>>>><<<]+[<<>]"},
+		{name: "back and forth off the right", source: "+>+<[>>>>>>>>>><<<<<<<<<]"},
+		{name: "back and forth off the left", source: "+>+>+[<<>]"},
+		{name: "back and forth growing tape", source: "+>+>+<<[>>>>>>>>>><<<<<<<<<]+", maxMemorySize: 64},
+		{name: "back and forth past the memory limit", source: "+[>>>>>>>>>>>><<<<<<<<<<<]", maxMemorySize: 12},
+		{name: "raising off the right", source: "+>+>+<<[>>>]", raise: true},
+		{name: "raising off the left", source: "+>+>+[<<>]", raise: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var ips []*interpreter.Interpreter
+			var errs []error
+			for _, unoptimized := range []bool{true, false} {
+				ip, err := interpreter.Load(strings.NewReader(tc.source), &interpreter.Config{
+					Writer:        io.Discard,
+					MemorySize:    8,
+					MaxMemorySize: tc.maxMemorySize,
+					Unoptimized:   unoptimized,
+
+					RaiseErrorOnOverflow: tc.raise,
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = ip.Run(context.Background())
+				ips = append(ips, ip)
+				errs = append(errs, err)
+			}
+
+			raw, optimized := ips[0], ips[1]
+			if fmt.Sprint(errs[1]) != fmt.Sprint(errs[0]) {
+				t.Errorf("error: got: %v, expected: %v", errs[1], errs[0])
+			}
+			if optimized.Pointer != raw.Pointer {
+				t.Errorf("pointer: got: %d, expected: %d", optimized.Pointer, raw.Pointer)
+			}
+			if optimized.Steps() != raw.Steps() {
+				t.Errorf("steps: got: %d, expected: %d", optimized.Steps(), raw.Steps())
+			}
+			if optimized.MaxPointer() != raw.MaxPointer() {
+				t.Errorf("max pointer: got: %d, expected: %d", optimized.MaxPointer(), raw.MaxPointer())
+			}
+			if !bytes.Equal(optimized.Memory, raw.Memory) {
+				t.Errorf("memory: got: %v, expected: %v", optimized.Memory, raw.Memory)
+			}
+		})
+	}
+}
+
+func TestInterpreterPointerBounds(t *testing.T) {
+	testCases := []struct {
+		source   string
+		raise    bool
+		pointer  int
+		expected error
+	}{
+		{source: "<>+", pointer: 0},
+		{source: "<+", pointer: -1, expected: interpreter.ErrMemoryOverflow},
+		{source: "<.", pointer: -1, expected: interpreter.ErrMemoryOverflow},
+		{source: "<[-]", pointer: -1, expected: interpreter.ErrMemoryOverflow},
+		{source: "<>+", raise: true, pointer: 0, expected: interpreter.ErrMemoryOverflow},
+		{source: ">>>>>+", pointer: 5, expected: interpreter.ErrMemoryOverflow},
+		{source: "+[>+]", pointer: 4, expected: interpreter.ErrMemoryOverflow},
+		{source: "+>+>+>+[>]", pointer: 4, expected: interpreter.ErrMemoryOverflow},
+		{source: ">>+<+<+[<]", pointer: -1, expected: interpreter.ErrMemoryOverflow},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			c := &interpreter.Config{MemorySize: 4, RaiseErrorOnOverflow: tc.raise}
+			ip, err := interpreter.Load(strings.NewReader(tc.source), c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = ip.Run(context.Background())
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("got: %v, expected: %v", err, tc.expected)
+			}
+			if ip.Pointer != tc.pointer {
+				t.Errorf("pointer got: %d, expected: %d", ip.Pointer, tc.pointer)
+			}
+		})
+	}
+}
+
+func FuzzInterpreter(f *testing.F) {
+	examples, err := filepath.Glob("../example/*.bf")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, example := range examples {
+		src, err := os.ReadFile(example)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(src, []byte{}, false)
+	}
+	f.Add([]byte("<+>>>[>]<<[<]"), []byte("ab"), true)
+	f.Add([]byte(",[.,]"), []byte("hello"), false)
+	f.Add([]byte("+(-:)+++:>(:)<:"), []byte{}, false)
+
+	f.Fuzz(func(t *testing.T, src []byte, input []byte, raise bool) {
+		for _, unoptimized := range []bool{false, true} {
+			c := &interpreter.Config{
+				Writer:               io.Discard,
+				Reader:               bytes.NewReader(input),
+				MemorySize:           64,
+				RaiseErrorOnOverflow: raise,
+				MaxSteps:             10_000,
+				MaxCallDepth:         64,
+				Unoptimized:          unoptimized,
+				Syntax:               lexer.Config{Procedures: true},
+			}
+			// any error is fine, panics are not.
+			interpreter.Run(context.Background(), bytes.NewReader(src), c)
+		}
+	})
+}
+
+var benchmarkExamples = []string{"hello-world", "prime", "mandelbrot"}
+
+func BenchmarkInterpreter(b *testing.B) {
+	for _, name := range benchmarkExamples {
+		src, err := os.ReadFile(filepath.Join("../example", name+".bf"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(name, func(b *testing.B) {
+			steps := 0
+			for n := 0; n < b.N; n++ {
+				b.StopTimer()
+				c := &interpreter.Config{Writer: io.Discard, MemorySize: 30000, OutputBufferSize: 4096}
+				ip, err := interpreter.Load(bytes.NewReader(src), c)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+
+				if _, err := ip.Run(context.Background()); err != nil {
+					b.Fatal(err)
+				}
+				steps += ip.Steps()
+			}
+			b.ReportMetric(float64(steps)/b.Elapsed().Seconds(), "steps/s")
+		})
+	}
+}
+
+func BenchmarkZeroSearch(b *testing.B) {
+	const tapeSize = 30000
+
+	testCases := []struct {
+		name    string
+		source  string
+		pointer int
+	}{
+		{name: "right", source: "[>]", pointer: 1},
+		{name: "left", source: "[<]", pointer: tapeSize - 2},
+		{name: "right by 3", source: "[>>>]", pointer: 2},
+		{name: "left by 3", source: "[<<<]", pointer: tapeSize - 3},
+	}
+
+	for _, tc := range testCases {
+		p, err := parser.Parse(strings.NewReader(tc.source))
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(tc.name, func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				b.StopTimer()
+				// every cell but the first and the last is set.
+				ip := interpreter.NewInterpreter(p, &interpreter.Config{Writer: io.Discard, MemorySize: tapeSize})
+				for cell := 1; cell < tapeSize-1; cell++ {
+					ip.Memory[cell] = 1
+				}
+				ip.Pointer = tc.pointer
+				b.StartTimer()
+
+				if _, err := ip.Run(context.Background()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package interpreter
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrStepLimitExceeded   = fmt.Errorf("step limit exceeded")
+	ErrOutputLimitExceeded = fmt.Errorf("output limit exceeded")
+	ErrInputLimitExceeded  = fmt.Errorf("input limit exceeded")
+	ErrMemoryLimitExceeded = fmt.Errorf("memory limit exceeded")
+)
+
+// usage is shared by forked threads so that limits bound them together.
+type usage struct {
+	// mu guards the counters once threads share them, single runs don't
+	// pay for locking.
+	mu     *sync.Mutex
+	steps  int64
+	input  int64
+	output int64
+}
+
+func (u *usage) add(counter *int64, n int, limit int, limitErr error) error {
+	if u.mu != nil {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+	}
+	if limit > 0 && int(*counter)+n > limit {
+		return fmt.Errorf("%w: %d", limitErr, limit)
+	}
+	*counter += int64(n)
+	return nil
+}
+
+func (u *usage) get(counter *int64) int {
+	if u.mu != nil {
+		u.mu.Lock()
+		defer u.mu.Unlock()
+	}
+	return int(*counter)
+}
+
+// Steps returns the number of source instructions executed so far. Optimized
+// expressions count every instruction they stand for, so the number matches
+// an unoptimized run.
+func (i *Interpreter) Steps() int {
+	if i.usage == nil {
+		return 0
+	}
+	return i.usage.get(&i.usage.steps)
+}
+
+func (i *Interpreter) InputBytes() int {
+	if i.usage == nil {
+		return 0
+	}
+	return i.usage.get(&i.usage.input)
+}
+
+func (i *Interpreter) OutputBytes() int {
+	if i.usage == nil {
+		return 0
+	}
+	return i.usage.get(&i.usage.output)
+}
+
+func (i *Interpreter) charge(n int) error {
+	if err := i.usage.add(&i.usage.steps, n, i.Config.MaxSteps, ErrStepLimitExceeded); err != nil {
+		return err
+	}
+	i.charged += n
+	return nil
+}
+
+func (i *Interpreter) chargeInput(n int) error {
+	return i.usage.add(&i.usage.input, n, i.Config.MaxInputBytes, ErrInputLimitExceeded)
+}
+
+func (i *Interpreter) chargeOutput(n int) error {
+	return i.usage.add(&i.usage.output, n, i.Config.MaxOutputBytes, ErrOutputLimitExceeded)
+}
+
+// reach records that the pointer moved right to pointer.
+func (i *Interpreter) reach(pointer int) error {
+	i.maxPointer = max(i.maxPointer, pointer)
+	return i.growMemory(pointer)
+}
+
+// growMemory extends the tape to hold pointer when MaxMemorySize allows it.
+func (i *Interpreter) growMemory(pointer int) error {
+	if pointer < len(i.Memory) || i.Config.MaxMemorySize <= i.Config.MemorySize {
+		return nil
+	}
+	if pointer >= i.Config.MaxMemorySize {
+		return fmt.Errorf("%w: %d to pointer beyond %d cells", ErrMemoryLimitExceeded, pointer, i.Config.MaxMemorySize)
+	}
+
+	size := min(max(pointer+1, 2*len(i.Memory)), i.Config.MaxMemorySize)
+	memory := make([]byte, size)
+	copy(memory, i.Memory)
+	i.Memory = memory
+	return nil
+}
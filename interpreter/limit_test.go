@@ -0,0 +1,138 @@
+package interpreter_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rosylilly/brainfxxk/interpreter"
+	"github.com/rosylilly/brainfxxk/parser"
+)
+
+func TestInterpreterLimits(t *testing.T) {
+	testCases := []struct {
+		name     string
+		source   string
+		input    string
+		config   interpreter.Config
+		output   string
+		expected error
+	}{
+		{
+			name:     "output within limit",
+			source:   "+...",
+			config:   interpreter.Config{MaxOutputBytes: 3},
+			output:   "\x01\x01\x01",
+			expected: nil,
+		},
+		{
+			name:     "runaway output",
+			source:   "+[.]",
+			config:   interpreter.Config{MaxOutputBytes: 5},
+			output:   "\x01\x01\x01\x01\x01",
+			expected: interpreter.ErrOutputLimitExceeded,
+		},
+		{
+			name:     "input",
+			source:   ",.,.,.",
+			input:    "abc",
+			config:   interpreter.Config{MaxInputBytes: 2},
+			output:   "ab",
+			expected: interpreter.ErrInputLimitExceeded,
+		},
+		{
+			name:     "input up to limit",
+			source:   ",[.,]",
+			input:    "Hello",
+			config:   interpreter.Config{MaxInputBytes: 5},
+			output:   "Hello",
+			expected: nil,
+		},
+		{
+			name:     "tape growth",
+			source:   "+[>+]",
+			config:   interpreter.Config{MemorySize: 4, MaxMemorySize: 100},
+			expected: interpreter.ErrMemoryLimitExceeded,
+		},
+		{
+			name:     "scan growth",
+			source:   "+[>+<-]>[>>]+.",
+			config:   interpreter.Config{MemorySize: 2, MaxMemorySize: 8},
+			output:   "\x01",
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			c := tc.config
+			c.Writer = w
+			c.Reader = strings.NewReader(tc.input)
+			if c.MemorySize == 0 {
+				c.MemorySize = 30000
+			}
+
+			_, err := interpreter.Run(context.Background(), strings.NewReader(tc.source), &c)
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("got: %v, expected: %v", err, tc.expected)
+			}
+			if w.String() != tc.output {
+				t.Errorf("output: got: %q, expected: %q", w.String(), tc.output)
+			}
+		})
+	}
+}
+
+func TestInterpreterMemoryGrowth(t *testing.T) {
+	p, err := parser.Parse(strings.NewReader(">>>>>>>>>+"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ip := interpreter.NewInterpreter(p, &interpreter.Config{
+		Writer:        &bytes.Buffer{},
+		Reader:        strings.NewReader(""),
+		MemorySize:    2,
+		MaxMemorySize: 16,
+	})
+	if _, err := ip.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ip.Memory) != 10 {
+		t.Errorf("memory size: got: %v, expected: %v", len(ip.Memory), 10)
+	}
+	if ip.Memory[9] != 1 {
+		t.Errorf("memory: got: %v, expected: %v", ip.Memory[9], 1)
+	}
+}
+
+func TestInterpreterUsage(t *testing.T) {
+	p, err := parser.Parse(strings.NewReader(">>>+[<]<<,[.,]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := &bytes.Buffer{}
+	ip := interpreter.NewInterpreter(p, &interpreter.Config{
+		Writer:     w,
+		Reader:     strings.NewReader("ab"),
+		MemorySize: 8,
+	})
+	if _, err := ip.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if ip.MaxPointer() != 3 {
+		t.Errorf("max pointer: got %d, expected 3", ip.MaxPointer())
+	}
+	if ip.InputBytes() != 2 || ip.OutputBytes() != 2 {
+		t.Errorf("bytes: got %d read and %d written, expected 2 and 2", ip.InputBytes(), ip.OutputBytes())
+	}
+	if ip.Nodes() != 8 {
+		t.Errorf("nodes: got %d, expected 8", ip.Nodes())
+	}
+}
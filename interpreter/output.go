@@ -0,0 +1,88 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrUnknownFlushPolicy = fmt.Errorf("unknown flush policy")
+)
+
+// FlushPolicy tells when buffered output is written to Config.Writer. The
+// buffer is always written when it is full and when a run stops, including
+// runs stopped by an error or cancellation.
+type FlushPolicy int
+
+const (
+	// FlushOnExit only writes the buffer when it is full or the run stops.
+	FlushOnExit FlushPolicy = 0
+	// FlushOnNewline also writes the buffer after every '\n'.
+	FlushOnNewline FlushPolicy = 1 << 0
+	// FlushOnInput also writes the buffer before reading input, so that
+	// interactive programs show their prompts.
+	FlushOnInput FlushPolicy = 1 << 1
+)
+
+var flushPolicyNames = []struct {
+	policy FlushPolicy
+	name   string
+}{
+	{FlushOnNewline, "newline"},
+	{FlushOnInput, "input"},
+}
+
+func (p FlushPolicy) String() string {
+	var names []string
+	for _, n := range flushPolicyNames {
+		if p&n.policy != 0 {
+			names = append(names, n.name)
+		}
+	}
+	if len(names) == 0 {
+		return "exit"
+	}
+	return strings.Join(names, ",")
+}
+
+// ParseFlushPolicy parses a comma separated list of "newline", "input" and
+// "exit".
+func ParseFlushPolicy(s string) (FlushPolicy, error) {
+	policy := FlushOnExit
+	for _, name := range strings.Split(s, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case "exit":
+		case "newline":
+			policy |= FlushOnNewline
+		case "input":
+			policy |= FlushOnInput
+		default:
+			return 0, fmt.Errorf("%w: %s", ErrUnknownFlushPolicy, name)
+		}
+	}
+	return policy, nil
+}
+
+// Flush writes the buffered output. Run flushes by itself, callers stepping
+// through a program with a buffer should flush when they want to see output.
+func (i *Interpreter) Flush() error {
+	if i.buffer == nil {
+		return nil
+	}
+	return i.buffer.Flush()
+}
+
+func (i *Interpreter) emit(b byte) error {
+	if i.buffer == nil {
+		_, err := i.writer.Write([]byte{b})
+		return err
+	}
+
+	if err := i.buffer.WriteByte(b); err != nil {
+		return err
+	}
+	if b == '\n' && i.Config.FlushPolicy&FlushOnNewline != 0 {
+		return i.buffer.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package interpreter_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rosylilly/brainfxxk/interpreter"
+)
+
+// chunkWriter records every write it receives.
+type chunkWriter struct {
+	w      io.Writer
+	chunks []string
+}
+
+func (cw *chunkWriter) Write(p []byte) (int, error) {
+	cw.chunks = append(cw.chunks, string(p))
+	if cw.w == nil {
+		return len(p), nil
+	}
+	return cw.w.Write(p)
+}
+
+func TestInterpreterOutputBuffer(t *testing.T) {
+	testCases := []struct {
+		name     string
+		source   string
+		input    string
+		config   interpreter.Config
+		timeout  bool
+		chunks   []string
+		expected error
+	}{
+		{
+			name:   "unbuffered",
+			source: ",.,.,.",
+			input:  "a\nb",
+			chunks: []string{"a", "\n", "b"},
+		},
+		{
+			name:   "flush on exit",
+			source: ",.,.,.",
+			input:  "a\nb",
+			config: interpreter.Config{OutputBufferSize: 16},
+			chunks: []string{"a\nb"},
+		},
+		{
+			name:   "flush on newline",
+			source: ",.,.,.",
+			input:  "a\nb",
+			config: interpreter.Config{OutputBufferSize: 16, FlushPolicy: interpreter.FlushOnNewline},
+			chunks: []string{"a\n", "b"},
+		},
+		{
+			name:   "flush on input",
+			source: ",.,.,.",
+			input:  "a\nb",
+			config: interpreter.Config{OutputBufferSize: 16, FlushPolicy: interpreter.FlushOnInput},
+			chunks: []string{"a", "\n", "b"},
+		},
+		{
+			name:   "flush on size",
+			source: ",.,.,.",
+			input:  "a\nb",
+			config: interpreter.Config{OutputBufferSize: 2},
+			chunks: []string{"a\n", "b"},
+		},
+		{
+			name:     "flush on error",
+			source:   "+.+.+.",
+			config:   interpreter.Config{OutputBufferSize: 16, MaxOutputBytes: 2},
+			chunks:   []string{"\x01\x02"},
+			expected: interpreter.ErrOutputLimitExceeded,
+		},
+		{
+			name:     "flush on cancel",
+			source:   "+.[>+<]",
+			config:   interpreter.Config{OutputBufferSize: 16},
+			timeout:  true,
+			chunks:   []string{"\x01"},
+			expected: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			if tc.timeout {
+				ctx, cancel = context.WithTimeout(ctx, 10*time.Millisecond)
+			}
+			defer cancel()
+
+			w := &chunkWriter{}
+			c := tc.config
+			c.MemorySize = 30000
+			c.Writer = w
+			c.Reader = strings.NewReader(tc.input)
+
+			_, err := interpreter.Run(ctx, strings.NewReader(tc.source), &c)
+			if !errors.Is(err, tc.expected) {
+				t.Errorf("got: %v, expected: %v", err, tc.expected)
+			}
+			if strings.Join(w.chunks, "|") != strings.Join(tc.chunks, "|") {
+				t.Errorf("got: %q, expected: %q", w.chunks, tc.chunks)
+			}
+		})
+	}
+}
+
+func TestParseFlushPolicy(t *testing.T) {
+	testCases := []struct {
+		source   string
+		expected interpreter.FlushPolicy
+		err      error
+	}{
+		{source: "exit", expected: interpreter.FlushOnExit},
+		{source: "newline", expected: interpreter.FlushOnNewline},
+		{source: "newline, input", expected: interpreter.FlushOnNewline | interpreter.FlushOnInput},
+		{source: "size", err: interpreter.ErrUnknownFlushPolicy},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			policy, err := interpreter.ParseFlushPolicy(tc.source)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("got: %v, expected: %v", err, tc.err)
+			}
+			if policy != tc.expected {
+				t.Errorf("got: %v, expected: %v", policy, tc.expected)
+			}
+		})
+	}
+}
+
+func BenchmarkMandelbrotOutput(b *testing.B) {
+	src, err := os.ReadFile("../example/mandelbrot.bf")
+	if err != nil {
+		b.Fatal(err)
+	}
+	null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer null.Close()
+
+	for _, size := range []int{0, 4096} {
+		name := "unbuffered"
+		if size > 0 {
+			name = "buffered"
+		}
+		b.Run(name, func(b *testing.B) {
+			w := &chunkWriter{w: null}
+			for n := 0; n < b.N; n++ {
+				c := &interpreter.Config{
+					Writer:           w,
+					MemorySize:       30000,
+					OutputBufferSize: size,
+				}
+				if _, err := interpreter.Run(context.Background(), strings.NewReader(string(src)), c); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(len(w.chunks))/float64(b.N), "writes/op")
+		})
+	}
+}
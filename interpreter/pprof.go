@@ -0,0 +1,136 @@
+package interpreter
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+)
+
+// WritePprof writes the profile in the gzipped protocol buffer format read by
+// "go tool pprof". Every expression is a function located at its source line,
+// nested under the loops and procedures enclosing it.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	strings := &stringTable{index: map[string]int{}}
+	strings.add("")
+
+	profile := &protoBuffer{}
+	for _, sampleType := range [][2]string{{"steps", "count"}, {"executions", "count"}} {
+		vt := &protoBuffer{}
+		vt.int(1, strings.add(sampleType[0]))
+		vt.int(2, strings.add(sampleType[1]))
+		profile.message(1, vt)
+	}
+
+	filename := strings.add(p.Filename)
+	for idx := range p.code {
+		in := &p.code[idx]
+		if in.end {
+			continue
+		}
+		id := uint64(idx + 1)
+		line, col := p.source.Position(in.pos())
+
+		fn := &protoBuffer{}
+		fn.uint(1, id)
+		fn.int(2, strings.add(snippet(in.expr)))
+		fn.int(4, filename)
+		fn.int(5, line)
+		profile.message(5, fn)
+
+		ln := &protoBuffer{}
+		ln.uint(1, id)
+		ln.int(2, line)
+		ln.int(3, col)
+		loc := &protoBuffer{}
+		loc.uint(1, id)
+		loc.message(4, ln)
+		profile.message(4, loc)
+
+		c := &p.counters[idx]
+		if c.steps.Load() == 0 && c.executions.Load() == 0 {
+			continue
+		}
+		stack := []uint64{}
+		for frame := idx; frame >= 0; frame = p.code[frame].parent {
+			stack = append(stack, uint64(frame+1))
+		}
+		sample := &protoBuffer{}
+		sample.packedUints(1, stack)
+		sample.packedInts(2, []int64{c.steps.Load(), c.executions.Load()})
+		profile.message(2, sample)
+	}
+
+	profile.int(14, strings.add("steps"))
+
+	for _, s := range strings.values {
+		profile.bytes(6, []byte(s))
+	}
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(profile.buf); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+type stringTable struct {
+	index  map[string]int
+	values []string
+}
+
+func (t *stringTable) add(s string) int {
+	if idx, ok := t.index[s]; ok {
+		return idx
+	}
+	t.index[s] = len(t.values)
+	t.values = append(t.values, s)
+	return len(t.values) - 1
+}
+
+type protoBuffer struct {
+	buf []byte
+}
+
+func (b *protoBuffer) key(field int, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (b *protoBuffer) varint(v uint64) {
+	b.buf = binary.AppendUvarint(b.buf, v)
+}
+
+func (b *protoBuffer) uint(field int, v uint64) {
+	b.key(field, 0)
+	b.varint(v)
+}
+
+func (b *protoBuffer) int(field int, v int) {
+	b.key(field, 0)
+	b.varint(uint64(v))
+}
+
+func (b *protoBuffer) bytes(field int, v []byte) {
+	b.key(field, 2)
+	b.varint(uint64(len(v)))
+	b.buf = append(b.buf, v...)
+}
+
+func (b *protoBuffer) message(field int, m *protoBuffer) {
+	b.bytes(field, m.buf)
+}
+
+func (b *protoBuffer) packedUints(field int, vs []uint64) {
+	packed := &protoBuffer{}
+	for _, v := range vs {
+		packed.varint(v)
+	}
+	b.bytes(field, packed.buf)
+}
+
+func (b *protoBuffer) packedInts(field int, vs []int64) {
+	packed := &protoBuffer{}
+	for _, v := range vs {
+		packed.varint(uint64(v))
+	}
+	b.bytes(field, packed.buf)
+}
@@ -0,0 +1,175 @@
+package interpreter
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/rosylilly/brainfxxk/ast"
+)
+
+type Profiler struct {
+	// Filename names the source in pprof output.
+	Filename string
+
+	code     []instruction
+	source   *ast.SourceMap
+	counters []profileCounter
+}
+
+type profileCounter struct {
+	executions atomic.Int64
+	iterations atomic.Int64
+	steps      atomic.Int64
+}
+
+type ProfileEntry struct {
+	Expression ast.Expression
+	Pos        int
+	Line       int
+	Column     int
+	// Executions counts how often the expression ran, or for blocks how
+	// often they were entered.
+	Executions int
+	// Iterations counts how often the body of a loop ran.
+	Iterations int
+	// Steps counts the source instructions of the expression itself, and
+	// TotalSteps adds those of a block's body.
+	Steps      int
+	TotalSteps int
+}
+
+func NewProfiler() *Profiler {
+	return &Profiler{}
+}
+
+func (p *Profiler) attach(code []instruction, source *ast.SourceMap) {
+	if source == nil {
+		source = ast.NewSourceMap(nil)
+	}
+	p.code = code
+	p.source = source
+	p.counters = make([]profileCounter, len(code))
+}
+
+func (p *Profiler) record(code []instruction, pc int, next int, steps int) {
+	in := &code[pc]
+	node := pc
+	if in.end {
+		node = in.jump
+	} else {
+		p.counters[node].executions.Add(1)
+	}
+
+	if _, ok := in.expr.(*ast.WhileExpression); ok {
+		// a loop runs its body when '[' falls through or ']' jumps back.
+		if (!in.end && next == pc+1) || (in.end && next == in.jump+1) {
+			p.counters[node].iterations.Add(1)
+		}
+	}
+	p.counters[node].steps.Add(int64(steps))
+}
+
+// Entries returns the profiled expressions ordered by their total cost.
+func (p *Profiler) Entries() []ProfileEntry {
+	entries := []ProfileEntry{}
+	for idx := range p.code {
+		in := &p.code[idx]
+		if in.end {
+			continue
+		}
+
+		c := &p.counters[idx]
+		if c.executions.Load() == 0 {
+			continue
+		}
+
+		pos := in.pos()
+		line, col := p.source.Position(pos)
+		entry := ProfileEntry{
+			Expression: in.expr,
+			Pos:        pos,
+			Line:       line,
+			Column:     col,
+			Executions: int(c.executions.Load()),
+			Iterations: int(c.iterations.Load()),
+			Steps:      int(c.steps.Load()),
+			TotalSteps: int(c.steps.Load()),
+		}
+		if in.jump > idx {
+			// closing brackets are recorded on their block, so the body
+			// range holds every nested cost.
+			for body := idx + 1; body < in.jump; body++ {
+				entry.TotalSteps += int(p.counters[body].steps.Load())
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].TotalSteps != entries[j].TotalSteps {
+			return entries[i].TotalSteps > entries[j].TotalSteps
+		}
+		return entries[i].Pos < entries[j].Pos
+	})
+	return entries
+}
+
+// WriteReport writes the limit most expensive expressions, all when limit is
+// not positive.
+func (p *Profiler) WriteReport(w io.Writer, limit int) error {
+	entries := p.Entries()
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	if _, err := fmt.Fprintf(w, "%-10s %12s %12s %12s %12s  %s\n", "location", "total", "self", "executions", "iterations", "code"); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		location := fmt.Sprintf("%d:%d", e.Line, e.Column)
+		if _, err := fmt.Fprintf(w, "%-10s %12d %12d %12d %12d  %s\n", location, e.TotalSteps, e.Steps, e.Executions, e.Iterations, snippet(e.Expression)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteAnnotatedSource writes src with the steps spent on every line.
+func (p *Profiler) WriteAnnotatedSource(w io.Writer, src []byte) error {
+	lines := strings.SplitAfter(string(src), "\n")
+	steps := make([]int, len(lines)+1)
+	for idx := range p.code {
+		in := &p.code[idx]
+		if in.end {
+			continue
+		}
+		line, _ := p.source.Position(in.pos())
+		if line < len(steps) {
+			steps[line] += int(p.counters[idx].steps.Load())
+		}
+	}
+
+	for n, text := range lines {
+		if text == "" {
+			continue
+		}
+		text = strings.TrimSuffix(text, "\n")
+		if _, err := fmt.Fprintf(w, "%12d | %s\n", steps[n+1], text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func snippet(expr ast.Expression) string {
+	const width = 32
+
+	s := strings.Join(strings.Fields(expr.String()), "")
+	if len(s) > width {
+		s = s[:width-3] + "..."
+	}
+	return s
+}
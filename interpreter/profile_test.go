@@ -0,0 +1,84 @@
+package interpreter_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/rosylilly/brainfxxk/interpreter"
+)
+
+func TestProfiler(t *testing.T) {
+	source := "++[>+++[>+<-]<-]\n>>.\n"
+
+	profiler := interpreter.NewProfiler()
+	_, err := interpreter.Run(context.Background(), strings.NewReader(source), &interpreter.Config{
+		Writer:     io.Discard,
+		MemorySize: 8,
+		Profiler:   profiler,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := profiler.Entries()
+	expected := []struct {
+		code       string
+		line, col  int
+		executions int
+		iterations int
+		steps      int
+		totalSteps int
+	}{
+		{code: "[>+++[>+<-]<-]", line: 1, col: 3, executions: 1, iterations: 2, steps: 3, totalSteps: 47},
+		{code: "[>+<-]", line: 1, col: 8, executions: 2, iterations: 6, steps: 8, totalSteps: 32},
+		{code: "+++", line: 1, col: 5, executions: 2, iterations: 0, steps: 6, totalSteps: 6},
+	}
+	if len(entries) < len(expected) {
+		t.Fatalf("got %d entries, expected at least %d", len(entries), len(expected))
+	}
+	for n, e := range expected {
+		got := entries[n]
+		if got.Expression.String() != e.code || got.Line != e.line || got.Column != e.col ||
+			got.Executions != e.executions || got.Iterations != e.iterations ||
+			got.Steps != e.steps || got.TotalSteps != e.totalSteps {
+			t.Errorf("entry %d: got %s at %d:%d (%d, %d, %d, %d), expected %+v", n, got.Expression, got.Line, got.Column, got.Executions, got.Iterations, got.Steps, got.TotalSteps, e)
+		}
+	}
+
+	report := &bytes.Buffer{}
+	if err := profiler.WriteReport(report, 2); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(report.String()), "\n"); len(lines) != 3 {
+		t.Errorf("expected a header and 2 entries, got:\n%s", report.String())
+	}
+
+	annotated := &bytes.Buffer{}
+	if err := profiler.WriteAnnotatedSource(annotated, []byte(source)); err != nil {
+		t.Fatal(err)
+	}
+	expectedSource := "          49 | ++[>+++[>+<-]<-]\n           3 | >>.\n"
+	if annotated.String() != expectedSource {
+		t.Errorf("got:\n%s\nexpected:\n%s", annotated.String(), expectedSource)
+	}
+
+	pprof := &bytes.Buffer{}
+	if err := profiler.WritePprof(pprof); err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(pprof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(raw, []byte("[>+<-]")) {
+		t.Errorf("expected the pprof string table to contain the loop")
+	}
+}
@@ -0,0 +1,156 @@
+package interpreter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rosylilly/brainfxxk/ast"
+)
+
+const snapshotVersion = 1
+
+var (
+	ErrInvalidSnapshot  = fmt.Errorf("invalid snapshot")
+	ErrSnapshotMismatch = fmt.Errorf("snapshot of another program")
+)
+
+// Snapshot is the state of a paused machine. It can only be restored into an
+// interpreter running the same program with the same syntax.
+type Snapshot struct {
+	Version     int    `json:"version"`
+	ProgramHash string `json:"program_hash"`
+
+	PC         int          `json:"pc"`
+	Count      int          `json:"count"`
+	Committed  int          `json:"committed"`
+	Memory     []byte       `json:"memory"`
+	Pointer    int          `json:"pointer"`
+	MaxPointer int          `json:"max_pointer"`
+	Procedures map[byte]int `json:"procedures,omitempty"`
+	Calls      []int        `json:"calls,omitempty"`
+
+	Steps       int64 `json:"steps"`
+	InputBytes  int64 `json:"input_bytes"`
+	OutputBytes int64 `json:"output_bytes"`
+}
+
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	s := &Snapshot{}
+	if err := json.NewDecoder(r).Decode(s); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+	}
+	if s.Version != snapshotVersion {
+		return nil, fmt.Errorf("%w: version %d", ErrInvalidSnapshot, s.Version)
+	}
+	return s, nil
+}
+
+func (s *Snapshot) Write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(s)
+}
+
+// Snapshot captures the state between two instructions, such as after Run
+// stopped on cancellation.
+func (i *Interpreter) Snapshot() (*Snapshot, error) {
+	if i.Config.Syntax.Fork {
+		return nil, fmt.Errorf("%w: in snapshots", ErrForkNotSupported)
+	}
+	if err := i.prepare(); err != nil {
+		return nil, err
+	}
+
+	s := i.snapshot()
+	s.ProgramHash = i.programHash()
+	return s, nil
+}
+
+func (i *Interpreter) snapshot() *Snapshot {
+	procedures := make(map[byte]int, len(i.procedures))
+	for cell, pc := range i.procedures {
+		procedures[cell] = pc
+	}
+	return &Snapshot{
+		Version:     snapshotVersion,
+		PC:          i.pc,
+		Count:       i.count,
+		Committed:   i.committed,
+		Memory:      append([]byte{}, i.Memory...),
+		Pointer:     i.Pointer,
+		MaxPointer:  i.maxPointer,
+		Procedures:  procedures,
+		Calls:       append([]int{}, i.calls...),
+		Steps:       i.usage.steps,
+		InputBytes:  i.usage.input,
+		OutputBytes: i.usage.output,
+	}
+}
+
+// Restore continues from s on the next Run or step. The reader supplies the
+// input from its start again, so the bytes read before the snapshot are
+// discarded.
+func (i *Interpreter) Restore(s *Snapshot) error {
+	if err := i.prepare(); err != nil {
+		return err
+	}
+	if s.ProgramHash != i.programHash() {
+		return ErrSnapshotMismatch
+	}
+	if s.PC < 0 || s.PC > len(i.code) {
+		return fmt.Errorf("%w: pc %d is out of the program", ErrInvalidSnapshot, s.PC)
+	}
+	for _, pc := range s.Calls {
+		if pc < 0 || pc > len(i.code) {
+			return fmt.Errorf("%w: return to %d is out of the program", ErrInvalidSnapshot, pc)
+		}
+	}
+	for cell, pc := range s.Procedures {
+		if pc < 0 || pc > len(i.code) {
+			return fmt.Errorf("%w: procedure %d at %d is out of the program", ErrInvalidSnapshot, cell, pc)
+		}
+	}
+
+	// the last read may have hit the end of the input.
+	if _, err := io.CopyN(io.Discard, i.reader, s.InputBytes); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: skipping %d bytes of input: %w", ErrInvalidSnapshot, s.InputBytes, err)
+	}
+
+	i.restore(s)
+	return nil
+}
+
+func (i *Interpreter) restore(s *Snapshot) {
+	i.pc = s.PC
+	i.count = s.Count
+	i.committed = s.Committed
+	i.Memory = append(i.Memory[:0], s.Memory...)
+	i.Pointer = s.Pointer
+	i.maxPointer = s.MaxPointer
+	i.procedures = map[byte]int{}
+	for cell, pc := range s.Procedures {
+		i.procedures[cell] = pc
+	}
+	i.calls = append([]int{}, s.Calls...)
+	i.usage.steps = s.Steps
+	i.usage.input = s.InputBytes
+	i.usage.output = s.OutputBytes
+}
+
+// programHash identifies the compiled program, so a program counter from a
+// snapshot points at the same instruction.
+func (i *Interpreter) programHash() string {
+	h := sha256.New()
+	for idx := range i.code {
+		in := &i.code[idx]
+		code := in.expr.String()
+		switch in.expr.(type) {
+		case *ast.WhileExpression, *ast.ProcedureExpression:
+			code = traceCode(in)
+		}
+		fmt.Fprintf(h, "%d:%s\n", in.pos(), code)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
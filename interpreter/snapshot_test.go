@@ -0,0 +1,140 @@
+package interpreter_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rosylilly/brainfxxk/interpreter"
+	"github.com/rosylilly/brainfxxk/lexer"
+)
+
+func TestSnapshotResume(t *testing.T) {
+	ctx := context.Background()
+	source := ",[.,]+(>+<):++[>+<-]>."
+	input := "abc"
+	config := func(w *bytes.Buffer) *interpreter.Config {
+		return &interpreter.Config{
+			Writer:     w,
+			Reader:     strings.NewReader(input),
+			MemorySize: 8,
+			Syntax:     lexer.Config{Procedures: true},
+		}
+	}
+
+	whole := &bytes.Buffer{}
+	expectedCount, err := interpreter.Run(ctx, strings.NewReader(source), config(whole))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for steps := 0; steps < 30; steps++ {
+		first := &bytes.Buffer{}
+		ip, err := interpreter.Load(strings.NewReader(source), config(first))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for n := 0; n < steps && !ip.Finished(); n++ {
+			if _, err := ip.Step(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		snapshot, err := ip.Snapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved := &bytes.Buffer{}
+		if err := snapshot.Write(saved); err != nil {
+			t.Fatal(err)
+		}
+		snapshot, err = interpreter.ReadSnapshot(saved)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		second := &bytes.Buffer{}
+		resumed, err := interpreter.Load(strings.NewReader(source), config(second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := resumed.Restore(snapshot); err != nil {
+			t.Fatal(err)
+		}
+		count, err := resumed.Run(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if output := first.String() + second.String(); output != whole.String() {
+			t.Errorf("after %d steps, output: got %q, expected %q", steps, output, whole.String())
+		}
+		if count != expectedCount {
+			t.Errorf("after %d steps, count: got %d, expected %d", steps, count, expectedCount)
+		}
+	}
+}
+
+func TestSnapshotMismatch(t *testing.T) {
+	ip, err := interpreter.Load(strings.NewReader("+."), &interpreter.Config{MemorySize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := ip.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := interpreter.Load(strings.NewReader("-."), &interpreter.Config{MemorySize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Restore(snapshot); !errors.Is(err, interpreter.ErrSnapshotMismatch) {
+		t.Errorf("got: %v, expected: %v", err, interpreter.ErrSnapshotMismatch)
+	}
+
+	if _, err := interpreter.ReadSnapshot(strings.NewReader(`{"version": 0}`)); !errors.Is(err, interpreter.ErrInvalidSnapshot) {
+		t.Errorf("got: %v, expected: %v", err, interpreter.ErrInvalidSnapshot)
+	}
+}
+
+func TestSnapshotOutOfProgram(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(s *interpreter.Snapshot)
+	}{
+		{
+			name:   "pc",
+			modify: func(s *interpreter.Snapshot) { s.PC = 100 },
+		},
+		{
+			name:   "call stack",
+			modify: func(s *interpreter.Snapshot) { s.Calls = []int{-1} },
+		},
+		{
+			name:   "procedure",
+			modify: func(s *interpreter.Snapshot) { s.Procedures = map[byte]int{0: 100} },
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &interpreter.Config{MemorySize: 4, Syntax: lexer.Config{Procedures: true}}
+			ip, err := interpreter.Load(strings.NewReader("(+):."), config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			snapshot, err := ip.Snapshot()
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.modify(snapshot)
+
+			if err := ip.Restore(snapshot); !errors.Is(err, interpreter.ErrInvalidSnapshot) {
+				t.Errorf("got: %v, expected: %v", err, interpreter.ErrInvalidSnapshot)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package interpreter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/rosylilly/brainfxxk/ast"
+)
+
+var (
+	ErrUnknownTraceFilter = fmt.Errorf("unknown trace filter")
+)
+
+type TraceFilter int
+
+const (
+	TraceAll TraceFilter = iota
+	// TraceLoops only traces loop brackets and the loops folded into
+	// resets and scans.
+	TraceLoops
+	// TraceIO only traces input and output.
+	TraceIO
+)
+
+func (f TraceFilter) String() string {
+	switch f {
+	case TraceAll:
+		return "all"
+	case TraceLoops:
+		return "loops"
+	case TraceIO:
+		return "io"
+	}
+	return "unknown"
+}
+
+func ParseTraceFilter(s string) (TraceFilter, error) {
+	for _, f := range []TraceFilter{TraceAll, TraceLoops, TraceIO} {
+		if strings.EqualFold(s, f.String()) {
+			return f, nil
+		}
+	}
+	return TraceAll, fmt.Errorf("%w: %s", ErrUnknownTraceFilter, s)
+}
+
+// Tracer writes a line per executed instruction:
+//
+//	step=12 at=1:5 code=+++ ptr=0 cell=0->3
+//
+// where cell is the value under the pointer before and after the instruction.
+type Tracer struct {
+	Writer io.Writer
+	Filter TraceFilter
+	// StartPos and EndPos restrict tracing to instructions at source
+	// positions in [StartPos, EndPos). EndPos zero means no upper bound.
+	StartPos int
+	EndPos   int
+	// Sample writes only every Sample-th matching instruction, all of them
+	// when it is not above 1.
+	Sample int
+
+	mu      sync.Mutex
+	matched int
+}
+
+func NewTracer(w io.Writer) *Tracer {
+	return &Tracer{Writer: w}
+}
+
+func (t *Tracer) match(in *instruction) bool {
+	pos := in.pos()
+	if pos < t.StartPos || (t.EndPos > 0 && pos >= t.EndPos) {
+		return false
+	}
+
+	switch t.Filter {
+	case TraceLoops:
+		switch in.expr.(type) {
+		case *ast.WhileExpression, *ast.ValueResetExpression, *ast.ZeroSearchExpression:
+			return true
+		}
+		return false
+	case TraceIO:
+		switch in.expr.(type) {
+		case *ast.InputExpression, *ast.OutputExpression:
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+func (i *Interpreter) trace(in *instruction, pointer int, before byte) error {
+	t := i.Config.Trace
+	if !t.match(in) {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.matched++
+	if t.Sample > 1 && (t.matched-1)%t.Sample != 0 {
+		return nil
+	}
+
+	at := fmt.Sprint(in.pos())
+	if i.Source != nil {
+		line, col := i.Source.Position(in.pos())
+		at = fmt.Sprintf("%d:%d", line, col)
+	}
+	ptr := fmt.Sprint(pointer)
+	if i.Pointer != pointer {
+		ptr = fmt.Sprintf("%d->%d", pointer, i.Pointer)
+	}
+	after := "?"
+	if i.Pointer >= 0 && i.Pointer < len(i.Memory) {
+		after = fmt.Sprint(i.Memory[i.Pointer])
+	}
+
+	_, err := fmt.Fprintf(t.Writer, "step=%d at=%s code=%s ptr=%s cell=%d->%s\n", i.Steps(), at, traceCode(in), ptr, before, after)
+	return err
+}
+
+func traceCode(in *instruction) string {
+	switch in.expr.(type) {
+	case *ast.WhileExpression:
+		if in.end {
+			return "]"
+		}
+		return "["
+	case *ast.ProcedureExpression:
+		if in.end {
+			return ")"
+		}
+		return "("
+	}
+	return snippet(in.expr)
+}
@@ -0,0 +1,120 @@
+package interpreter_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/rosylilly/brainfxxk/interpreter"
+	"github.com/rosylilly/brainfxxk/lexer"
+)
+
+func TestTracer(t *testing.T) {
+	testCases := []struct {
+		name     string
+		source   string
+		filter   interpreter.TraceFilter
+		start    int
+		end      int
+		sample   int
+		expected string
+	}{
+		{
+			name:   "all",
+			source: "++>[-]<.",
+			filter: interpreter.TraceAll,
+			expected: "step=2 at=1:1 code=++ ptr=0 cell=0->2\n" +
+				"step=3 at=1:3 code=> ptr=0->1 cell=2->0\n" +
+				"step=4 at=1:4 code=[-] ptr=1 cell=0->0\n" +
+				"step=5 at=1:7 code=< ptr=1->0 cell=0->2\n" +
+				"step=6 at=1:8 code=. ptr=0 cell=2->2\n",
+		},
+		{
+			name:   "loops",
+			source: "+[-\n>]",
+			filter: interpreter.TraceLoops,
+			expected: "step=2 at=1:2 code=[ ptr=0 cell=1->1\n" +
+				"step=5 at=2:2 code=] ptr=1 cell=0->0\n",
+		},
+		{
+			name:     "clear loops",
+			source:   "++[+\n]",
+			filter:   interpreter.TraceLoops,
+			expected: "step=511 at=1:3 code=[+] ptr=0 cell=2->0\n",
+		},
+		{
+			name:     "io",
+			source:   "+.>.",
+			filter:   interpreter.TraceIO,
+			expected: "step=2 at=1:2 code=. ptr=0 cell=1->1\nstep=4 at=1:4 code=. ptr=1 cell=0->0\n",
+		},
+		{
+			name:     "position range",
+			source:   "+>+>+",
+			start:    1,
+			end:      3,
+			expected: "step=2 at=1:2 code=> ptr=0->1 cell=1->0\nstep=3 at=1:3 code=+ ptr=1 cell=0->1\n",
+		},
+		{
+			name:     "sample",
+			source:   "+>+>+",
+			sample:   2,
+			expected: "step=1 at=1:1 code=+ ptr=0 cell=0->1\nstep=3 at=1:3 code=+ ptr=1 cell=0->1\nstep=5 at=1:5 code=+ ptr=2 cell=0->1\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := &bytes.Buffer{}
+			tracer := interpreter.NewTracer(w)
+			tracer.Filter = tc.filter
+			tracer.StartPos = tc.start
+			tracer.EndPos = tc.end
+			tracer.Sample = tc.sample
+
+			_, err := interpreter.Run(context.Background(), strings.NewReader(tc.source), &interpreter.Config{
+				Writer:     io.Discard,
+				MemorySize: 8,
+				Trace:      tracer,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w.String() != tc.expected {
+				t.Errorf("got:\n%s\nexpected:\n%s", w.String(), tc.expected)
+			}
+		})
+	}
+}
+
+func TestTracerFork(t *testing.T) {
+	for _, memory := range []interpreter.ForkMemory{interpreter.SharedForkMemory, interpreter.CopiedForkMemory} {
+		w := &bytes.Buffer{}
+		// threads step concurrently, the steps they trace must not race.
+		_, err := interpreter.Run(context.Background(), strings.NewReader("Y+>+>+"), &interpreter.Config{
+			Writer:     io.Discard,
+			MemorySize: 8,
+			Syntax:     lexer.Config{Fork: true},
+			ForkMemory: memory,
+			Trace:      interpreter.NewTracer(w),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lines := strings.Count(w.String(), "\n"); lines != 11 {
+			t.Errorf("lines: got: %v, expected: %v", lines, 11)
+		}
+	}
+}
+
+func TestParseTraceFilter(t *testing.T) {
+	if f, err := interpreter.ParseTraceFilter("IO"); err != nil || f != interpreter.TraceIO {
+		t.Errorf("got: %v, %v, expected: %v", f, err, interpreter.TraceIO)
+	}
+	if _, err := interpreter.ParseTraceFilter("jumps"); !errors.Is(err, interpreter.ErrUnknownTraceFilter) {
+		t.Errorf("got: %v, expected: %v", err, interpreter.ErrUnknownTraceFilter)
+	}
+}
@@ -0,0 +1,39 @@
+package lexer
+
+type Config struct {
+	// Dialect spells the instructions, Standard when nil.
+	Dialect *Dialect
+	// DebugDump enables the '#' instruction that dumps the tape.
+	DebugDump bool
+	// InputSeparator makes '!' end the program. Everything after it is the
+	// program's input.
+	InputSeparator bool
+	// Procedures enables the pbrain instructions '(', ')' and ':'.
+	Procedures bool
+	// Fork enables the Brainfork instruction 'Y'.
+	Fork bool
+}
+
+func (c *Config) tokens() map[byte]TokenType {
+	tokens := map[byte]TokenType{}
+	if c.Dialect == nil || c.Dialect == Standard {
+		for b, t := range ByteToTokenType {
+			tokens[b] = t
+		}
+	}
+	if c.DebugDump {
+		tokens['#'] = DebugToken
+	}
+	if c.InputSeparator {
+		tokens['!'] = InputSeparatorToken
+	}
+	if c.Procedures {
+		tokens['('] = ProcedureStartToken
+		tokens[')'] = ProcedureEndToken
+		tokens[':'] = CallToken
+	}
+	if c.Fork {
+		tokens['Y'] = ForkToken
+	}
+	return tokens
+}
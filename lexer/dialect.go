@@ -0,0 +1,260 @@
+package lexer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrInvalidDialect = errors.New("invalid dialect")
+	ErrUnknownDialect = errors.New("unknown dialect")
+)
+
+var instructionTokenTypes = []TokenType{
+	PointerIncrementToken,
+	PointerDecrementToken,
+	ValueIncrementToken,
+	ValueDecrementToken,
+	OutputToken,
+	InputToken,
+	WhileStartToken,
+	WhileEndToken,
+}
+
+// Dialect spells the eight instructions with arbitrary words. A space in a
+// spelling matches any run of whitespace in the source. Like the standard
+// symbols, spellings match anywhere, even inside words of a comment.
+type Dialect struct {
+	Name string
+	// Separator is written between instructions when translating into the
+	// dialect.
+	Separator string
+	Tokens    map[TokenType]string
+
+	spellings []spelling
+}
+
+type spelling struct {
+	text      string
+	tokenType TokenType
+}
+
+var (
+	Standard = mustDialect("standard", "", map[TokenType]string{
+		PointerIncrementToken: ">",
+		PointerDecrementToken: "<",
+		ValueIncrementToken:   "+",
+		ValueDecrementToken:   "-",
+		OutputToken:           ".",
+		InputToken:            ",",
+		WhileStartToken:       "[",
+		WhileEndToken:         "]",
+	})
+	Ook = mustDialect("ook", " ", map[TokenType]string{
+		PointerIncrementToken: "Ook. Ook?",
+		PointerDecrementToken: "Ook? Ook.",
+		ValueIncrementToken:   "Ook. Ook.",
+		ValueDecrementToken:   "Ook! Ook!",
+		OutputToken:           "Ook! Ook.",
+		InputToken:            "Ook. Ook!",
+		WhileStartToken:       "Ook! Ook?",
+		WhileEndToken:         "Ook? Ook!",
+	})
+	Blub = mustDialect("blub", " ", map[TokenType]string{
+		PointerIncrementToken: "Blub. Blub?",
+		PointerDecrementToken: "Blub? Blub.",
+		ValueIncrementToken:   "Blub. Blub.",
+		ValueDecrementToken:   "Blub! Blub!",
+		OutputToken:           "Blub! Blub.",
+		InputToken:            "Blub. Blub!",
+		WhileStartToken:       "Blub! Blub?",
+		WhileEndToken:         "Blub? Blub!",
+	})
+	Alphuck = mustDialect("alphuck", "", map[TokenType]string{
+		PointerIncrementToken: "a",
+		PointerDecrementToken: "c",
+		ValueIncrementToken:   "e",
+		ValueDecrementToken:   "i",
+		OutputToken:           "j",
+		InputToken:            "o",
+		WhileStartToken:       "p",
+		WhileEndToken:         "s",
+	})
+	Pikalang = mustDialect("pikalang", " ", map[TokenType]string{
+		PointerIncrementToken: "pipi",
+		PointerDecrementToken: "pichu",
+		ValueIncrementToken:   "pi",
+		ValueDecrementToken:   "ka",
+		OutputToken:           "pikachu",
+		InputToken:            "pikapi",
+		WhileStartToken:       "pika",
+		WhileEndToken:         "chu",
+	})
+
+	Dialects = map[string]*Dialect{
+		Standard.Name: Standard,
+		Ook.Name:      Ook,
+		Blub.Name:     Blub,
+		Alphuck.Name:  Alphuck,
+		Pikalang.Name: Pikalang,
+	}
+)
+
+func NewDialect(name string, separator string, tokens map[TokenType]string) (*Dialect, error) {
+	seen := map[string]bool{}
+	spellings := []spelling{}
+	for _, t := range instructionTokenTypes {
+		text := tokens[t]
+		if strings.TrimSpace(text) == "" {
+			return nil, fmt.Errorf("%w: %s has no spelling for %c", ErrInvalidDialect, name, TokenTypeToByte[t])
+		}
+		if seen[text] {
+			return nil, fmt.Errorf("%w: %s spells two instructions as %q", ErrInvalidDialect, name, text)
+		}
+		seen[text] = true
+		spellings = append(spellings, spelling{text: text, tokenType: t})
+	}
+
+	// Longer spellings win so that words sharing a prefix can be told apart.
+	sort.SliceStable(spellings, func(i, j int) bool {
+		return len(spellings[i].text) > len(spellings[j].text)
+	})
+
+	return &Dialect{
+		Name:      name,
+		Separator: separator,
+		Tokens:    tokens,
+		spellings: spellings,
+	}, nil
+}
+
+func mustDialect(name string, separator string, tokens map[TokenType]string) *Dialect {
+	d, err := NewDialect(name, separator, tokens)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// LoadDialect reads a dialect from JSON such as
+// {"name": "words", "separator": " ", "tokens": {">": "right", ...}}
+// where tokens are keyed by the standard instruction.
+func LoadDialect(r io.Reader) (*Dialect, error) {
+	var def struct {
+		Name      string            `json:"name"`
+		Separator string            `json:"separator"`
+		Tokens    map[string]string `json:"tokens"`
+	}
+	if err := json.NewDecoder(r).Decode(&def); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidDialect, err)
+	}
+
+	tokens := map[TokenType]string{}
+	for instruction, spelling := range def.Tokens {
+		if len(instruction) != 1 {
+			return nil, fmt.Errorf("%w: %s is not an instruction", ErrInvalidDialect, instruction)
+		}
+		t, ok := ByteToTokenType[instruction[0]]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is not an instruction", ErrInvalidDialect, instruction)
+		}
+		tokens[t] = spelling
+	}
+	return NewDialect(def.Name, def.Separator, tokens)
+}
+
+func LookupDialect(name string) (*Dialect, error) {
+	if d, ok := Dialects[strings.ToLower(name)]; ok {
+		return d, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownDialect, name)
+}
+
+// match returns the token spelled at the start of b and the number of bytes
+// it spans. short reports that b ended before a spelling could be told
+// apart, so more of the source has to be read first. At the end of the
+// source b is all there is and short is never reported.
+func (d *Dialect) match(b []byte, end bool) (t TokenType, n int, ok bool, short bool) {
+	for _, s := range d.spellings {
+		n, ok, short := s.match(b)
+		if short && !end {
+			return CommentToken, 0, false, true
+		}
+		if ok {
+			return s.tokenType, n, true, false
+		}
+	}
+	return CommentToken, 0, false, false
+}
+
+func (s spelling) match(b []byte) (n int, ok bool, short bool) {
+	for i := 0; i < len(s.text); i++ {
+		if s.text[i] == ' ' {
+			start := n
+			for n < len(b) && isSpace(b[n]) {
+				n++
+			}
+			if n == len(b) {
+				return 0, false, true
+			}
+			if n == start {
+				return 0, false, false
+			}
+			continue
+		}
+		if n >= len(b) {
+			return 0, false, true
+		}
+		if b[n] != s.text[i] {
+			return 0, false, false
+		}
+		n++
+	}
+	return n, true, false
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+// Translate rewrites the source from one dialect into another. Comments are
+// dropped except for line breaks.
+func Translate(w io.Writer, r io.Reader, from, to *Dialect) error {
+	l := NewLexerWithConfig(r, &Config{Dialect: from})
+	written := false
+	for {
+		token, err := l.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var s string
+		switch {
+		case token.Type == CommentToken && token.Byte == '\n':
+			s = "\n"
+			written = false
+		case token.Type == CommentToken:
+			continue
+		default:
+			var ok bool
+			if s, ok = to.Tokens[token.Type]; !ok {
+				s = string(token.Byte)
+			}
+			if written {
+				s = to.Separator + s
+			}
+			written = true
+		}
+
+		if _, err := io.WriteString(w, s); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package lexer_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rosylilly/brainfxxk/lexer"
+)
+
+func TestLexerDialect(t *testing.T) {
+	testCases := []struct {
+		input    string
+		dialect  *lexer.Dialect
+		expected []*lexer.Token
+	}{
+		{
+			input:   "Ook. Ook? Ook!\n  Ook? x",
+			dialect: lexer.Ook,
+			expected: []*lexer.Token{
+				{Type: lexer.PointerIncrementToken, Byte: '>', Pos: 0},
+				{Type: lexer.CommentToken, Byte: ' ', Pos: 9},
+				{Type: lexer.WhileStartToken, Byte: '[', Pos: 10},
+				{Type: lexer.CommentToken, Byte: ' ', Pos: 21},
+				{Type: lexer.CommentToken, Byte: 'x', Pos: 22},
+			},
+		},
+		{
+			input:   "Ook.\t\t\t        \n\n\tOok?",
+			dialect: lexer.Ook,
+			expected: []*lexer.Token{
+				{Type: lexer.PointerIncrementToken, Byte: '>', Pos: 0},
+			},
+		},
+		{
+			input:   "pikachu pika pi",
+			dialect: lexer.Pikalang,
+			expected: []*lexer.Token{
+				{Type: lexer.OutputToken, Byte: '.', Pos: 0},
+				{Type: lexer.CommentToken, Byte: ' ', Pos: 7},
+				{Type: lexer.WhileStartToken, Byte: '[', Pos: 8},
+				{Type: lexer.CommentToken, Byte: ' ', Pos: 12},
+				{Type: lexer.ValueIncrementToken, Byte: '+', Pos: 13},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			l := lexer.NewLexerWithConfig(strings.NewReader(tc.input), &lexer.Config{Dialect: tc.dialect})
+
+			for _, expected := range tc.expected {
+				token, err := l.Next()
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if *token != *expected {
+					t.Errorf("expected %+v, but got %+v", expected, token)
+				}
+			}
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	source := "+[->.<],\n"
+
+	ook := &bytes.Buffer{}
+	if err := lexer.Translate(ook, strings.NewReader(source), lexer.Standard, lexer.Ook); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "Ook. Ook. Ook! Ook? Ook! Ook! Ook. Ook? Ook! Ook. Ook? Ook. Ook? Ook! Ook. Ook!\n"
+	if ook.String() != expected {
+		t.Errorf("got: %q, expected: %q", ook.String(), expected)
+	}
+
+	standard := &bytes.Buffer{}
+	if err := lexer.Translate(standard, ook, lexer.Ook, lexer.Standard); err != nil {
+		t.Fatal(err)
+	}
+	if standard.String() != source {
+		t.Errorf("got: %q, expected: %q", standard.String(), source)
+	}
+}
+
+func TestLoadDialect(t *testing.T) {
+	d, err := lexer.LoadDialect(strings.NewReader(`{
+		"name": "words",
+		"separator": " ",
+		"tokens": {">": "right", "<": "left", "+": "up", "-": "down", ".": "say", ",": "ask", "[": "while", "]": "end"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := &bytes.Buffer{}
+	if err := lexer.Translate(w, strings.NewReader("up while down right end say"), d, lexer.Standard); err != nil {
+		t.Fatal(err)
+	}
+	if w.String() != "+[->]." {
+		t.Errorf("got: %q, expected: %q", w.String(), "+[->].")
+	}
+
+	_, err = lexer.LoadDialect(strings.NewReader(`{"name": "broken", "tokens": {">": "a", "<": "a"}}`))
+	if !errors.Is(err, lexer.ErrInvalidDialect) {
+		t.Errorf("got: %v, expected: %v", err, lexer.ErrInvalidDialect)
+	}
+
+	if _, err := lexer.LookupDialect("klingon"); !errors.Is(err, lexer.ErrUnknownDialect) {
+		t.Errorf("got: %v, expected: %v", err, lexer.ErrUnknownDialect)
+	}
+}
+
+func TestLexerDialectStream(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	go func() {
+		// the writer stays open, like a terminal waiting for the next line.
+		_, _ = w.Write([]byte("pi  \t pika\n"))
+	}()
+
+	tokens := make(chan *lexer.Token)
+	go func() {
+		defer close(tokens)
+		l := lexer.NewLexerWithConfig(r, &lexer.Config{Dialect: lexer.Pikalang})
+		for {
+			token, err := l.Next()
+			if err != nil {
+				return
+			}
+			tokens <- token
+		}
+	}()
+
+	expected := []lexer.TokenType{
+		lexer.ValueIncrementToken,
+		lexer.CommentToken,
+		lexer.CommentToken,
+		lexer.CommentToken,
+		lexer.CommentToken,
+		lexer.WhileStartToken,
+		lexer.CommentToken,
+	}
+	for _, e := range expected {
+		select {
+		case token := <-tokens:
+			if token.Type != e {
+				t.Errorf("got: %v, expected: %v", token.Type, e)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("lexer blocked waiting for %v", e)
+		}
+	}
+}
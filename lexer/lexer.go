@@ -1,40 +1,88 @@
 package lexer
 
 import (
+	"bufio"
 	"io"
 )
 
 type Lexer struct {
-	pos    int
-	reader io.Reader
+	pos     int
+	reader  *bufio.Reader
+	tokens  map[byte]TokenType
+	dialect *Dialect
 }
 
 func NewLexer(reader io.Reader) *Lexer {
 	return &Lexer{
 		pos:    0,
-		reader: reader,
+		reader: bufio.NewReader(reader),
+		tokens: ByteToTokenType,
 	}
 }
 
+func NewLexerWithConfig(reader io.Reader, c *Config) *Lexer {
+	l := &Lexer{
+		pos:    0,
+		reader: bufio.NewReader(reader),
+		tokens: c.tokens(),
+	}
+	if c.Dialect != nil && c.Dialect != Standard {
+		l.dialect = c.Dialect
+	}
+	return l
+}
+
 func (l *Lexer) Next() (*Token, error) {
-	b := make([]byte, 1)
-	n, err := l.reader.Read(b)
+	if l.dialect != nil {
+		// peek no further than the spellings need, reading a terminal or a
+		// pipe blocks until the bytes arrive.
+		for size := 1; ; size++ {
+			// Peek fails at the end of the source but still returns the rest.
+			b, err := l.reader.Peek(size)
+			t, n, ok, short := l.dialect.match(b, err != nil)
+			if short {
+				continue
+			}
+			if !ok {
+				break
+			}
+			token := &Token{
+				Type: t,
+				Byte: TokenTypeToByte[t],
+				Pos:  l.pos,
+			}
+			if _, err := l.reader.Discard(n); err != nil {
+				return nil, err
+			}
+			l.pos += n
+			return token, nil
+		}
+	}
+
+	b, err := l.reader.ReadByte()
 	if err != nil {
 		return nil, err
 	}
 
 	tokenType := CommentToken
-	if t, ok := ByteToTokenType[b[0]]; ok {
+	if t, ok := l.tokens[b]; ok {
 		tokenType = t
 	}
 
 	token := &Token{
 		Type: tokenType,
-		Byte: b[0],
+		Byte: b,
 		Pos:  l.pos,
 	}
 
-	l.pos += n
+	l.pos += 1
 
 	return token, nil
 }
+
+// Rest returns the unread remainder of the source.
+func (l *Lexer) Rest() ([]byte, error) {
+	b, err := io.ReadAll(l.reader)
+	l.pos += len(b)
+	return b, err
+}
@@ -1,6 +1,11 @@
 package lexer_test
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -77,3 +82,113 @@ func TestLexer(t *testing.T) {
 		})
 	}
 }
+
+func TestLexerWithConfig(t *testing.T) {
+	testCases := []struct {
+		input    string
+		config   *lexer.Config
+		expected []lexer.TokenType
+	}{
+		{
+			input:    "+#-",
+			config:   &lexer.Config{},
+			expected: []lexer.TokenType{lexer.ValueIncrementToken, lexer.CommentToken, lexer.ValueDecrementToken},
+		},
+		{
+			input:    "+#-",
+			config:   &lexer.Config{DebugDump: true},
+			expected: []lexer.TokenType{lexer.ValueIncrementToken, lexer.DebugToken, lexer.ValueDecrementToken},
+		},
+		{
+			input:    "(:)",
+			config:   &lexer.Config{},
+			expected: []lexer.TokenType{lexer.CommentToken, lexer.CommentToken, lexer.CommentToken},
+		},
+		{
+			input:    "(:)",
+			config:   &lexer.Config{Procedures: true},
+			expected: []lexer.TokenType{lexer.ProcedureStartToken, lexer.CallToken, lexer.ProcedureEndToken},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			l := lexer.NewLexerWithConfig(strings.NewReader(tc.input), tc.config)
+
+			for _, expected := range tc.expected {
+				token, err := l.Next()
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if token.Type != expected {
+					t.Errorf("expected type %v, but got %v", expected, token.Type)
+				}
+			}
+		})
+	}
+}
+
+func FuzzLexer(f *testing.F) {
+	examples, err := filepath.Glob("../example/*.bf")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, example := range examples {
+		src, err := os.ReadFile(example)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(src)
+	}
+	f.Add([]byte("+(-:)#Y!abc"))
+
+	config := &lexer.Config{DebugDump: true, InputSeparator: true, Procedures: true, Fork: true}
+	f.Fuzz(func(t *testing.T, src []byte) {
+		for _, l := range []*lexer.Lexer{
+			lexer.NewLexer(bytes.NewReader(src)),
+			lexer.NewLexerWithConfig(bytes.NewReader(src), config),
+		} {
+			// every byte comes back as one token at its position.
+			lexed := []byte{}
+			for {
+				token, err := l.Next()
+				if err != nil {
+					break
+				}
+				if token.Pos != len(lexed) {
+					t.Fatalf("token %q at %d, expected at %d", token.Byte, token.Pos, len(lexed))
+				}
+				lexed = append(lexed, token.Byte)
+			}
+			if !bytes.Equal(lexed, src) {
+				t.Fatalf("got: %q, expected: %q", lexed, src)
+			}
+		}
+	})
+}
+
+var benchmarkExamples = []string{"hello-world", "prime", "mandelbrot"}
+
+func BenchmarkLexer(b *testing.B) {
+	for _, name := range benchmarkExamples {
+		src, err := os.ReadFile(filepath.Join("../example", name+".bf"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(src)))
+			for n := 0; n < b.N; n++ {
+				l := lexer.NewLexer(bytes.NewReader(src))
+				for {
+					if _, err := l.Next(); err != nil {
+						if !errors.Is(err, io.EOF) {
+							b.Fatal(err)
+						}
+						break
+					}
+				}
+			}
+		})
+	}
+}
@@ -12,6 +12,12 @@ const (
 	InputToken
 	WhileStartToken
 	WhileEndToken
+	DebugToken
+	InputSeparatorToken
+	ProcedureStartToken
+	ProcedureEndToken
+	CallToken
+	ForkToken
 )
 
 var (
@@ -27,6 +33,19 @@ var (
 	}
 )
 
+var (
+	TokenTypeToByte = map[TokenType]byte{
+		PointerIncrementToken: '>',
+		PointerDecrementToken: '<',
+		ValueIncrementToken:   '+',
+		ValueDecrementToken:   '-',
+		OutputToken:           '.',
+		InputToken:            ',',
+		WhileStartToken:       '[',
+		WhileEndToken:         ']',
+	}
+)
+
 type Token struct {
 	Type TokenType
 	Byte byte
@@ -1,142 +1,78 @@
 package optimizer
 
 import (
+	"bytes"
+	"fmt"
+
 	"github.com/rosylilly/brainfxxk/ast"
 )
 
-type Optimizer struct {
+type Config struct {
+	// Level runs the passes up to this level, from 0 to MaxLevel.
+	Level int
+	// DisabledPasses names passes skipped at any level.
+	DisabledPasses []string
 }
 
-func NewOptimizer() *Optimizer {
-	return &Optimizer{}
+func DefaultConfig() *Config {
+	return &Config{Level: DefaultLevel}
 }
 
-func (o *Optimizer) Optimize(p *ast.Program) (*ast.Program, error) {
-	exprs, err := o.optimizeExpressions(p.Expressions)
-	if err != nil {
-		return nil, err
-	}
-
-	prog := &ast.Program{
-		Expressions: exprs,
+// Passes returns the passes the config runs, in order.
+func (c *Config) Passes() ([]*Pass, error) {
+	if c.Level < 0 || c.Level > MaxLevel {
+		return nil, fmt.Errorf("%w: %d is not between 0 and %d", ErrInvalidLevel, c.Level, MaxLevel)
 	}
-
-	return prog, nil
-}
-
-func (o *Optimizer) optimizeExpressions(exprs []ast.Expression) ([]ast.Expression, error) {
-	optimized := []ast.Expression{}
-	for _, expr := range exprs {
-		optExpr, err := o.optimizeExpression(expr)
-		if err != nil {
+	disabled := map[string]bool{}
+	for _, name := range c.DisabledPasses {
+		if _, err := LookupPass(name); err != nil {
 			return nil, err
 		}
+		disabled[name] = true
+	}
 
-		if _, ok := optExpr.(*ast.Comment); ok {
-			continue
+	passes := []*Pass{}
+	for _, p := range Passes {
+		if p.Level <= c.Level && !disabled[p.Name] {
+			passes = append(passes, p)
 		}
+	}
+	return passes, nil
+}
 
-		switch optExpr.(type) {
-		case *ast.PointerIncrementExpression:
-			if len(optimized) > 0 {
-				if last, ok := optimized[len(optimized)-1].(*ast.PointerMoveExpression); ok {
-					last.Count += 1
-					last.Expressions = append(last.Expressions, optExpr)
-					continue
-				}
-			}
-
-			optExpr = &ast.PointerMoveExpression{
-				Count:       1,
-				Expressions: []ast.Expression{optExpr},
-			}
-
-		case *ast.PointerDecrementExpression:
-			if len(optimized) > 0 {
-				if last, ok := optimized[len(optimized)-1].(*ast.PointerMoveExpression); ok {
-					last.Count -= 1
-					last.Expressions = append(last.Expressions, optExpr)
-					continue
-				}
-			}
-
-			optExpr = &ast.PointerMoveExpression{
-				Count:       -1,
-				Expressions: []ast.Expression{optExpr},
-			}
-
-		case *ast.ValueIncrementExpression:
-			if len(optimized) > 0 {
-				if last, ok := optimized[len(optimized)-1].(*ast.ValueChangeExpression); ok {
-					last.Count += 1
-					last.Expressions = append(last.Expressions, optExpr)
-					continue
-				}
-			}
-
-			optExpr = &ast.ValueChangeExpression{
-				Count:       1,
-				Expressions: []ast.Expression{optExpr},
-			}
-		case *ast.ValueDecrementExpression:
-			if len(optimized) > 0 {
-				if last, ok := optimized[len(optimized)-1].(*ast.ValueChangeExpression); ok {
-					last.Count -= 1
-					last.Expressions = append(last.Expressions, optExpr)
-					continue
-				}
-			}
-
-			optExpr = &ast.ValueChangeExpression{
-				Count:       -1,
-				Expressions: []ast.Expression{optExpr},
-			}
-		case *ast.WhileExpression:
-			if len(optExpr.(*ast.WhileExpression).Body) == 1 {
-				switch optExpr.(*ast.WhileExpression).Body[0].(type) {
-				case *ast.ValueDecrementExpression:
-					optExpr = &ast.ValueResetExpression{Pos: optExpr.StartPos()}
-				}
-			} else {
-				opBody, err := o.optimizeExpressions(optExpr.(*ast.WhileExpression).Body)
-				if err != nil {
-					return nil, err
-				}
+type Optimizer struct {
+	config *Config
+	// Stats holds a PassStats for every pass the last Optimize ran.
+	Stats []PassStats
+}
 
-				// Filter out comments from the optimized body
-				nonCommentBody := []ast.Expression{}
-				for _, expr := range opBody {
-					if _, ok := expr.(*ast.Comment); !ok {
-						nonCommentBody = append(nonCommentBody, expr)
-					}
-				}
+func NewOptimizer() *Optimizer {
+	return NewOptimizerWithConfig(DefaultConfig())
+}
 
-				if len(nonCommentBody) == 1 {
-					switch e := nonCommentBody[0].(type) {
-					case *ast.PointerMoveExpression:
-						optExpr = &ast.ZeroSearchExpression{
-							StartPosition: optExpr.StartPos(),
-							EndPosition:   optExpr.EndPos(),
-							SearchWindow:  e.Count,
-						}
-					default:
-						optExpr.(*ast.WhileExpression).Body = nonCommentBody
-					}
-				} else {
-					optExpr.(*ast.WhileExpression).Body = nonCommentBody
-				}
-			}
-		}
+func NewOptimizerWithConfig(c *Config) *Optimizer {
+	return &Optimizer{config: c}
+}
 
-		if _, ok := optExpr.(*ast.Comment); !ok {
-			optimized = append(optimized, optExpr)
-		}
+func (o *Optimizer) Optimize(p *ast.Program) (*ast.Program, error) {
+	passes, err := o.config.Passes()
+	if err != nil {
+		return nil, err
 	}
 
-	return optimized, nil
-}
+	// passes rewrite the expressions in place, so they work on a copy.
+	exprs := ast.CloneExpressions(p.Expressions)
+	o.Stats = make([]PassStats, 0, len(passes))
+	for _, pass := range passes {
+		before := countNodes(exprs)
+		exprs = walk(exprs, pass.Run)
+		o.Stats = append(o.Stats, PassStats{Name: pass.Name, NodesBefore: before, NodesAfter: countNodes(exprs)})
+	}
 
-func (o *Optimizer) optimizeExpression(expr ast.Expression) (ast.Expression, error) {
+	prog := &ast.Program{
+		Expressions: exprs,
+		Input:       bytes.Clone(p.Input),
+	}
 
-	return expr, nil
+	return prog, nil
 }
@@ -1,11 +1,17 @@
 package optimizer_test
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/rosylilly/brainfxxk/ast"
+	"github.com/rosylilly/brainfxxk/lexer"
 	"github.com/rosylilly/brainfxxk/optimizer"
 	"github.com/rosylilly/brainfxxk/parser"
 )
@@ -113,6 +119,147 @@ func TestOptimizer(t *testing.T) {
 				},
 			},
 		},
+		{
+			source: "+[+]",
+			expected: &ast.Program{
+				Expressions: []ast.Expression{
+					&ast.ValueChangeExpression{
+						Count: 1,
+						Expressions: []ast.Expression{
+							&ast.ValueIncrementExpression{Pos: 0},
+						},
+					},
+					&ast.ValueResetExpression{
+						Pos: 1,
+						Loop: &ast.WhileExpression{
+							StartPosition: 1,
+							EndPosition:   3,
+							Body: []ast.Expression{
+								&ast.ValueChangeExpression{
+									Count: 1,
+									Expressions: []ast.Expression{
+										&ast.ValueIncrementExpression{Pos: 2},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "[-x--]",
+			expected: &ast.Program{
+				Expressions: []ast.Expression{
+					&ast.ValueResetExpression{
+						Pos: 0,
+						Loop: &ast.WhileExpression{
+							StartPosition: 0,
+							EndPosition:   5,
+							Body: []ast.Expression{
+								&ast.ValueChangeExpression{
+									Count: -3,
+									Expressions: []ast.Expression{
+										&ast.ValueDecrementExpression{Pos: 1},
+										&ast.ValueDecrementExpression{Pos: 3},
+										&ast.ValueDecrementExpression{Pos: 4},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "[--]",
+			expected: &ast.Program{
+				Expressions: []ast.Expression{
+					&ast.WhileExpression{
+						StartPosition: 0,
+						EndPosition:   3,
+						Body: []ast.Expression{
+							&ast.ValueChangeExpression{
+								Count: -2,
+								Expressions: []ast.Expression{
+									&ast.ValueDecrementExpression{Pos: 1},
+									&ast.ValueDecrementExpression{Pos: 2},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "[[>]]",
+			expected: &ast.Program{
+				Expressions: []ast.Expression{
+					&ast.WhileExpression{
+						StartPosition: 0,
+						EndPosition:   4,
+						Body: []ast.Expression{
+							&ast.ZeroSearchExpression{
+								StartPosition: 1,
+								EndPosition:   3,
+								SearchWindow:  1,
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "[>x>]",
+			expected: &ast.Program{
+				Expressions: []ast.Expression{
+					&ast.ZeroSearchExpression{
+						StartPosition: 0,
+						EndPosition:   4,
+						SearchWindow:  2,
+						Loop: &ast.WhileExpression{
+							StartPosition: 0,
+							EndPosition:   4,
+							Body: []ast.Expression{
+								&ast.PointerMoveExpression{
+									Count: 2,
+									Expressions: []ast.Expression{
+										&ast.PointerIncrementExpression{Pos: 1},
+										&ast.PointerIncrementExpression{Pos: 3},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			source: "+[<>]",
+			expected: &ast.Program{
+				Expressions: []ast.Expression{
+					&ast.ValueChangeExpression{
+						Count: 1,
+						Expressions: []ast.Expression{
+							&ast.ValueIncrementExpression{Pos: 0},
+						},
+					},
+					&ast.WhileExpression{
+						StartPosition: 1,
+						EndPosition:   4,
+						Body: []ast.Expression{
+							&ast.PointerMoveExpression{
+								Count: 0,
+								Expressions: []ast.Expression{
+									&ast.PointerDecrementExpression{Pos: 2},
+									&ast.PointerIncrementExpression{Pos: 3},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -135,6 +282,256 @@ func TestOptimizer(t *testing.T) {
 			if !reflect.DeepEqual(prog, tc.expected) {
 				t.Errorf("got: %#v, expected: %#v", prog, tc.expected)
 			}
+			checkPositions(t, prog.Expressions, len(tc.source))
+		})
+	}
+}
+
+func TestOptimizerProcedures(t *testing.T) {
+	p, err := parser.ParseWithConfig(strings.NewReader("(++ x --)"), &lexer.Config{Procedures: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prog, err := optimizer.NewOptimizer().Optimize(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &ast.Program{
+		Expressions: []ast.Expression{
+			&ast.ProcedureExpression{
+				StartPosition: 0,
+				EndPosition:   8,
+				Body: []ast.Expression{
+					&ast.ValueChangeExpression{
+						Count: 0,
+						Expressions: []ast.Expression{
+							&ast.ValueIncrementExpression{Pos: 1},
+							&ast.ValueIncrementExpression{Pos: 2},
+							&ast.ValueDecrementExpression{Pos: 6},
+							&ast.ValueDecrementExpression{Pos: 7},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(prog, expected) {
+		t.Errorf("got: %#v, expected: %#v", prog, expected)
+	}
+}
+
+func TestOptimizerConfig(t *testing.T) {
+	const source = ">>[-]<[<<]x"
+
+	testCases := []struct {
+		name     string
+		config   *optimizer.Config
+		expected string
+		stats    []optimizer.PassStats
+		err      error
+	}{
+		{
+			name:     "level 0",
+			config:   &optimizer.Config{Level: 0},
+			expected: "*ast.PointerIncrementExpression *ast.PointerIncrementExpression *ast.WhileExpression *ast.PointerDecrementExpression *ast.WhileExpression *ast.Comment",
+			stats:    []optimizer.PassStats{},
+		},
+		{
+			name:     "level 1",
+			config:   &optimizer.Config{Level: 1},
+			expected: "*ast.PointerMoveExpression *ast.WhileExpression *ast.PointerMoveExpression *ast.WhileExpression",
+			stats: []optimizer.PassStats{
+				{Name: "strip-comments", NodesBefore: 9, NodesAfter: 8},
+				{Name: "fold", NodesBefore: 8, NodesAfter: 6},
+			},
+		},
+		{
+			name:     "level 2",
+			config:   optimizer.DefaultConfig(),
+			expected: "*ast.PointerMoveExpression *ast.ValueResetExpression *ast.PointerMoveExpression *ast.ZeroSearchExpression",
+			stats: []optimizer.PassStats{
+				{Name: "strip-comments", NodesBefore: 9, NodesAfter: 8},
+				{Name: "fold", NodesBefore: 8, NodesAfter: 6},
+				{Name: "reset", NodesBefore: 6, NodesAfter: 5},
+				{Name: "scan", NodesBefore: 5, NodesAfter: 4},
+			},
+		},
+		{
+			name:     "disabled passes",
+			config:   &optimizer.Config{Level: 2, DisabledPasses: []string{"fold", "reset"}},
+			expected: "*ast.PointerIncrementExpression *ast.PointerIncrementExpression *ast.WhileExpression *ast.PointerDecrementExpression *ast.ZeroSearchExpression",
+			stats: []optimizer.PassStats{
+				{Name: "strip-comments", NodesBefore: 9, NodesAfter: 8},
+				{Name: "scan", NodesBefore: 8, NodesAfter: 6},
+			},
+		},
+		{
+			name:   "unknown pass",
+			config: &optimizer.Config{Level: 2, DisabledPasses: []string{"inline"}},
+			err:    optimizer.ErrUnknownPass,
+		},
+		{
+			name:   "invalid level",
+			config: &optimizer.Config{Level: optimizer.MaxLevel + 1},
+			err:    optimizer.ErrInvalidLevel,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := parser.Parse(strings.NewReader(source))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			o := optimizer.NewOptimizerWithConfig(tc.config)
+			prog, err := o.Optimize(p)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("expected %v, got %v", tc.err, err)
+			}
+			if err != nil {
+				return
+			}
+
+			types := []string{}
+			for _, expr := range prog.Expressions {
+				types = append(types, fmt.Sprintf("%T", expr))
+			}
+			if got := strings.Join(types, " "); got != tc.expected {
+				t.Errorf("got: %s, expected: %s", got, tc.expected)
+			}
+			checkPositions(t, prog.Expressions, len(source))
+			if !reflect.DeepEqual(o.Stats, tc.stats) {
+				t.Errorf("stats: got: %+v, expected: %+v", o.Stats, tc.stats)
+			}
+		})
+	}
+}
+
+func TestOptimizerInput(t *testing.T) {
+	sources := map[string]string{
+		"nested loops": "+[>[-]<[>>]x-]<<",
+		"spinning":     "+[<>]",
+		"comments":     "a[b-c]d[>e]",
+		"clear loops":  "[+]>[[-]+++]>[-x]",
+		"scans":        "[>x>]<[<<>]",
+	}
+	examples, err := filepath.Glob("../example/*.bf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, example := range examples {
+		src, err := os.ReadFile(example)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources[filepath.Base(example)] = string(src)
+	}
+
+	for name, src := range sources {
+		t.Run(name, func(t *testing.T) {
+			p, err := parser.Parse(strings.NewReader(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkOptimize(t, p)
+		})
+	}
+}
+
+// checkOptimize fails when optimizing p changes it, or when optimizing the
+// result again changes anything.
+func checkOptimize(t *testing.T, p *ast.Program) {
+	t.Helper()
+
+	original := p.Clone()
+	optimized, err := optimizer.NewOptimizer().Optimize(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, original) {
+		t.Fatalf("the input program changed:\n%s\n%s", p, original)
+	}
+
+	again := optimized.Clone()
+	twice, err := optimizer.NewOptimizer().Optimize(again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(twice, optimized) {
+		t.Fatalf("optimizing again changed the program:\n%s\n%s", twice, optimized)
+	}
+}
+
+func FuzzOptimizer(f *testing.F) {
+	examples, err := filepath.Glob("../example/*.bf")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, example := range examples {
+		src, err := os.ReadFile(example)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(src)
+	}
+	f.Add([]byte(">>[-]<[<]+[<>]>[>>]"))
+
+	f.Fuzz(func(t *testing.T, src []byte) {
+		p, err := parser.Parse(bytes.NewReader(src))
+		if err != nil {
+			return
+		}
+		optimized, err := optimizer.NewOptimizer().Optimize(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkPositions(t, optimized.Expressions, len(src))
+		_ = optimized.String()
+		checkOptimize(t, p)
+	})
+}
+
+// checkPositions fails unless every expression lies within the source.
+func checkPositions(t *testing.T, exprs []ast.Expression, size int) {
+	t.Helper()
+
+	for _, expr := range exprs {
+		start, end := expr.StartPos(), expr.EndPos()
+		if start < 0 || start > end || end >= size {
+			t.Fatalf("%T %q at %d:%d is outside %d bytes of source", expr, expr.String(), start, end, size)
+		}
+		switch e := expr.(type) {
+		case *ast.WhileExpression:
+			checkPositions(t, e.Body, size)
+		case *ast.ProcedureExpression:
+			checkPositions(t, e.Body, size)
+		}
+	}
+}
+
+var benchmarkExamples = []string{"hello-world", "prime", "mandelbrot"}
+
+func BenchmarkOptimizer(b *testing.B) {
+	for _, name := range benchmarkExamples {
+		src, err := os.ReadFile(filepath.Join("../example", name+".bf"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		p, err := parser.Parse(bytes.NewReader(src))
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(src)))
+			for n := 0; n < b.N; n++ {
+				if _, err := optimizer.NewOptimizer().Optimize(p); err != nil {
+					b.Fatal(err)
+				}
+			}
 		})
 	}
 }
@@ -0,0 +1,221 @@
+package optimizer
+
+import (
+	"fmt"
+
+	"github.com/rosylilly/brainfxxk/ast"
+)
+
+var (
+	ErrUnknownPass  = fmt.Errorf("unknown optimization pass")
+	ErrInvalidLevel = fmt.Errorf("invalid optimization level")
+)
+
+const (
+	// MaxLevel runs every pass, level 0 runs none.
+	MaxLevel     = 2
+	DefaultLevel = MaxLevel
+)
+
+// Pass rewrites one list of expressions. Passes run one after another over
+// the whole program, innermost bodies first, so each sees what the earlier
+// ones made of the program.
+type Pass struct {
+	Name string
+	// Level is the lowest optimization level the pass runs at.
+	Level int
+	Run   func(exprs []ast.Expression) []ast.Expression
+}
+
+// Passes is the pipeline, in the order the passes run.
+var Passes = []*Pass{
+	{Name: "strip-comments", Level: 1, Run: stripComments},
+	{Name: "fold", Level: 1, Run: foldRuns},
+	{Name: "reset", Level: 2, Run: detectResets},
+	{Name: "scan", Level: 2, Run: detectScans},
+}
+
+func LookupPass(name string) (*Pass, error) {
+	for _, p := range Passes {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownPass, name)
+}
+
+// PassStats counts the nodes of the program around a pass. Folded
+// expressions count as one node, loops and procedures add their bodies.
+type PassStats struct {
+	Name        string
+	NodesBefore int
+	NodesAfter  int
+}
+
+// walk runs pass over every body in exprs and then over exprs itself.
+func walk(exprs []ast.Expression, pass func([]ast.Expression) []ast.Expression) []ast.Expression {
+	for _, expr := range exprs {
+		switch e := expr.(type) {
+		case *ast.WhileExpression:
+			e.Body = walk(e.Body, pass)
+		case *ast.ProcedureExpression:
+			e.Body = walk(e.Body, pass)
+		}
+	}
+	return pass(exprs)
+}
+
+func countNodes(exprs []ast.Expression) int {
+	nodes := 0
+	for _, expr := range exprs {
+		nodes++
+		switch e := expr.(type) {
+		case *ast.WhileExpression:
+			nodes += countNodes(e.Body)
+		case *ast.ProcedureExpression:
+			nodes += countNodes(e.Body)
+		}
+	}
+	return nodes
+}
+
+func stripComments(exprs []ast.Expression) []ast.Expression {
+	stripped := make([]ast.Expression, 0, len(exprs))
+	for _, expr := range exprs {
+		if _, ok := expr.(*ast.Comment); !ok {
+			stripped = append(stripped, expr)
+		}
+	}
+	return stripped
+}
+
+// foldRuns folds runs of pointer moves into PointerMoveExpression and runs
+// of value changes into ValueChangeExpression.
+func foldRuns(exprs []ast.Expression) []ast.Expression {
+	folded := make([]ast.Expression, 0, len(exprs))
+	for _, expr := range exprs {
+		var last ast.Expression
+		if len(folded) > 0 {
+			last = folded[len(folded)-1]
+		}
+
+		switch expr.(type) {
+		case *ast.PointerIncrementExpression, *ast.PointerDecrementExpression:
+			move, ok := last.(*ast.PointerMoveExpression)
+			if !ok {
+				move = &ast.PointerMoveExpression{}
+				folded = append(folded, move)
+			}
+			move.Count += pointerDelta(expr)
+			move.Expressions = append(move.Expressions, expr)
+		case *ast.ValueIncrementExpression, *ast.ValueDecrementExpression:
+			change, ok := last.(*ast.ValueChangeExpression)
+			if !ok {
+				change = &ast.ValueChangeExpression{}
+				folded = append(folded, change)
+			}
+			change.Count += valueDelta(expr)
+			change.Expressions = append(change.Expressions, expr)
+		default:
+			folded = append(folded, expr)
+		}
+	}
+	return folded
+}
+
+func pointerDelta(expr ast.Expression) int {
+	switch e := expr.(type) {
+	case *ast.PointerIncrementExpression:
+		return 1
+	case *ast.PointerDecrementExpression:
+		return -1
+	case *ast.PointerMoveExpression:
+		return e.Count
+	}
+	return 0
+}
+
+func valueDelta(expr ast.Expression) int {
+	switch e := expr.(type) {
+	case *ast.ValueIncrementExpression:
+		return 1
+	case *ast.ValueDecrementExpression:
+		return -1
+	case *ast.ValueChangeExpression:
+		return e.Count
+	}
+	return 0
+}
+
+// detectResets turns loops that only change the cell by an odd amount, like
+// "[-]" or "[+++]", into ValueResetExpression. Even changes may never reach
+// zero.
+func detectResets(exprs []ast.Expression) []ast.Expression {
+	for idx, expr := range exprs {
+		loop, ok := expr.(*ast.WhileExpression)
+		if !ok || !changesOnlyValue(loop.Body) {
+			continue
+		}
+		reset := &ast.ValueResetExpression{Pos: loop.StartPos(), Loop: loop}
+		if reset.Step()%2 == 0 {
+			continue
+		}
+		if loop.EndPos()-loop.StartPos() == 2 && reset.Step() == -1 {
+			reset.Loop = nil
+		}
+		exprs[idx] = reset
+	}
+	return exprs
+}
+
+func changesOnlyValue(exprs []ast.Expression) bool {
+	for _, expr := range exprs {
+		switch expr.(type) {
+		case *ast.ValueIncrementExpression, *ast.ValueDecrementExpression, *ast.ValueChangeExpression, *ast.Comment:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// detectScans turns loops that only move the pointer, like "[>>]" or
+// "[<<>]", into ZeroSearchExpression.
+func detectScans(exprs []ast.Expression) []ast.Expression {
+	for idx, expr := range exprs {
+		loop, ok := expr.(*ast.WhileExpression)
+		if !ok || !movesOnlyPointer(loop.Body) {
+			continue
+		}
+		window := 0
+		for _, e := range loop.Body {
+			window += pointerDelta(e)
+		}
+		// "[<>]" never moves, it only spins.
+		if window == 0 {
+			continue
+		}
+
+		search := &ast.ZeroSearchExpression{
+			StartPosition: loop.StartPos(),
+			EndPosition:   loop.EndPos(),
+			SearchWindow:  window,
+		}
+		if loop.EndPos()-loop.StartPos() != max(window, -window)+1 {
+			search.Loop = loop
+		}
+		exprs[idx] = search
+	}
+	return exprs
+}
+
+func movesOnlyPointer(exprs []ast.Expression) bool {
+	for _, expr := range exprs {
+		switch expr.(type) {
+		case *ast.PointerIncrementExpression, *ast.PointerDecrementExpression, *ast.PointerMoveExpression, *ast.Comment:
+		default:
+			return false
+		}
+	}
+	return true
+}
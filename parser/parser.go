@@ -23,6 +23,12 @@ func Parse(r io.Reader) (*ast.Program, error) {
 	return p.Parse()
 }
 
+func ParseWithConfig(r io.Reader, c *lexer.Config) (*ast.Program, error) {
+	l := lexer.NewLexerWithConfig(r, c)
+	p := NewParser(l)
+	return p.Parse()
+}
+
 func NewParser(l *lexer.Lexer) *Parser {
 	return &Parser{
 		lexer: l,
@@ -32,7 +38,9 @@ func NewParser(l *lexer.Lexer) *Parser {
 func (p *Parser) Parse() (*ast.Program, error) {
 	exprs := []ast.Expression{}
 	stack := [][]ast.Expression{exprs}
+	var input []byte
 
+tokens:
 	for {
 		token, err := p.lexer.Next()
 		if err != nil && !errors.Is(err, io.EOF) {
@@ -55,6 +63,8 @@ func (p *Parser) Parse() (*ast.Program, error) {
 			exprs = append(exprs, &ast.OutputExpression{Pos: token.Pos})
 		case lexer.InputToken:
 			exprs = append(exprs, &ast.InputExpression{Pos: token.Pos})
+		case lexer.DebugToken:
+			exprs = append(exprs, &ast.DebugExpression{Pos: token.Pos})
 		case lexer.WhileStartToken:
 			expr := &ast.WhileExpression{
 				StartPosition: token.Pos,
@@ -83,6 +93,41 @@ func (p *Parser) Parse() (*ast.Program, error) {
 				we.EndPosition = token.Pos
 				we.Body = body
 			}
+		case lexer.ProcedureStartToken:
+			expr := &ast.ProcedureExpression{
+				StartPosition: token.Pos,
+				EndPosition:   token.Pos,
+				Body:          []ast.Expression{},
+			}
+			exprs = append(exprs, expr)
+			stack = append(stack, exprs)
+			exprs = expr.Body
+		case lexer.ProcedureEndToken:
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("%w: unexpected token %c at %d", ErrInvalidSyntax, token.Byte, token.Pos)
+			}
+			body := exprs
+			exprs = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			expr := exprs[len(exprs)-1]
+			if pe, ok := expr.(*ast.ProcedureExpression); !ok {
+				return nil, fmt.Errorf("%w: unexpected token %c at %d", ErrInvalidSyntax, token.Byte, token.Pos)
+			} else {
+				pe.EndPosition = token.Pos
+				pe.Body = body
+			}
+		case lexer.CallToken:
+			exprs = append(exprs, &ast.CallExpression{Pos: token.Pos})
+		case lexer.ForkToken:
+			exprs = append(exprs, &ast.ForkExpression{Pos: token.Pos})
+		case lexer.InputSeparatorToken:
+			rest, err := p.lexer.Rest()
+			if err != nil {
+				return nil, err
+			}
+			input = rest
+			break tokens
 		case lexer.CommentToken:
 			var expr ast.Expression
 			if len(exprs) > 0 {
@@ -99,10 +144,15 @@ func (p *Parser) Parse() (*ast.Program, error) {
 	}
 
 	if len(stack) != 1 {
+		parent := stack[len(stack)-1]
+		if _, ok := parent[len(parent)-1].(*ast.ProcedureExpression); ok {
+			return nil, fmt.Errorf("%w: unclosed procedure block", ErrInvalidSyntax)
+		}
 		return nil, fmt.Errorf("%w: unclosed while block", ErrInvalidSyntax)
 	}
 
 	return &ast.Program{
 		Expressions: exprs,
+		Input:       input,
 	}, nil
 }
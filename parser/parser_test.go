@@ -1,6 +1,10 @@
 package parser_test
 
 import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -143,3 +147,192 @@ func TestParserPrinter(t *testing.T) {
 		})
 	}
 }
+
+func TestParserWithConfig(t *testing.T) {
+	program, err := parser.ParseWithConfig(strings.NewReader("+[#-]#"), &lexer.Config{DebugDump: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &ast.Program{
+		Expressions: []ast.Expression{
+			&ast.ValueIncrementExpression{Pos: 0},
+			&ast.WhileExpression{
+				StartPosition: 1,
+				EndPosition:   4,
+				Body: []ast.Expression{
+					&ast.DebugExpression{Pos: 2},
+					&ast.ValueDecrementExpression{Pos: 3},
+				},
+			},
+			&ast.DebugExpression{Pos: 5},
+		},
+	}
+
+	if !reflect.DeepEqual(program, expected) {
+		t.Errorf("got: %v, expected: %v", program, expected)
+	}
+}
+
+func TestParserInputSeparator(t *testing.T) {
+	testCases := []struct {
+		input    string
+		config   *lexer.Config
+		expected *ast.Program
+	}{
+		{
+			input:  ",.!ab!c",
+			config: &lexer.Config{InputSeparator: true},
+			expected: &ast.Program{
+				Expressions: []ast.Expression{
+					&ast.InputExpression{Pos: 0},
+					&ast.OutputExpression{Pos: 1},
+				},
+				Input: []byte("ab!c"),
+			},
+		},
+		{
+			input:  ",.!",
+			config: &lexer.Config{InputSeparator: true},
+			expected: &ast.Program{
+				Expressions: []ast.Expression{
+					&ast.InputExpression{Pos: 0},
+					&ast.OutputExpression{Pos: 1},
+				},
+				Input: []byte{},
+			},
+		},
+		{
+			input:  ",.!ab",
+			config: &lexer.Config{},
+			expected: &ast.Program{
+				Expressions: []ast.Expression{
+					&ast.InputExpression{Pos: 0},
+					&ast.OutputExpression{Pos: 1},
+					&ast.Comment{Start: 2, End: 4, Body: []byte("!ab")},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			program, err := parser.ParseWithConfig(strings.NewReader(tc.input), tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(program, tc.expected) {
+				t.Errorf("got: %#v, expected: %#v", program, tc.expected)
+			}
+		})
+	}
+}
+
+func TestParserProcedures(t *testing.T) {
+	program, err := parser.ParseWithConfig(strings.NewReader("+(>[:])<:"), &lexer.Config{Procedures: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &ast.Program{
+		Expressions: []ast.Expression{
+			&ast.ValueIncrementExpression{Pos: 0},
+			&ast.ProcedureExpression{
+				StartPosition: 1,
+				EndPosition:   6,
+				Body: []ast.Expression{
+					&ast.PointerIncrementExpression{Pos: 2},
+					&ast.WhileExpression{
+						StartPosition: 3,
+						EndPosition:   5,
+						Body: []ast.Expression{
+							&ast.CallExpression{Pos: 4},
+						},
+					},
+				},
+			},
+			&ast.PointerDecrementExpression{Pos: 7},
+			&ast.CallExpression{Pos: 8},
+		},
+	}
+
+	if !reflect.DeepEqual(program, expected) {
+		t.Errorf("got: %v, expected: %v", program, expected)
+	}
+	if program.String() != "+(>[:])<:" {
+		t.Errorf("got: %v, expected: %v", program.String(), "+(>[:])<:")
+	}
+
+	errorCases := []struct {
+		input    string
+		expected string
+	}{
+		{input: "+(", expected: "syntax error: unclosed procedure block"},
+		{input: "([)]", expected: "syntax error: unexpected token ) at 2"},
+		{input: "+)", expected: "syntax error: unexpected token ) at 1"},
+	}
+	for _, tc := range errorCases {
+		_, err := parser.ParseWithConfig(strings.NewReader(tc.input), &lexer.Config{Procedures: true})
+		if err == nil || err.Error() != tc.expected {
+			t.Errorf("got: %v, expected: %v", err, tc.expected)
+		}
+	}
+}
+
+func FuzzParser(f *testing.F) {
+	examples, err := filepath.Glob("../example/*.bf")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, example := range examples {
+		src, err := os.ReadFile(example)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(src)
+	}
+	f.Add([]byte("+[->[+-<]>]"))
+	f.Add([]byte("]["))
+
+	f.Fuzz(func(t *testing.T, src []byte) {
+		program, err := parser.Parse(bytes.NewReader(src))
+		if err != nil {
+			if !errors.Is(err, parser.ErrInvalidSyntax) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			return
+		}
+
+		// comments are kept, so the program prints as its source.
+		if program.String() != string(src) {
+			t.Fatalf("got: %q, expected: %q", program.String(), src)
+		}
+		reparsed, err := parser.Parse(strings.NewReader(program.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(reparsed, program) {
+			t.Fatalf("got: %#v, expected: %#v", reparsed, program)
+		}
+	})
+}
+
+var benchmarkExamples = []string{"hello-world", "prime", "mandelbrot"}
+
+func BenchmarkParser(b *testing.B) {
+	for _, name := range benchmarkExamples {
+		src, err := os.ReadFile(filepath.Join("../example", name+".bf"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(src)))
+			for n := 0; n < b.N; n++ {
+				if _, err := parser.Parse(bytes.NewReader(src)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}